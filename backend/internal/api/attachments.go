@@ -0,0 +1,238 @@
+package api
+
+import (
+	"backend/internal/blob"
+	"backend/internal/models"
+	"backend/internal/store"
+	"backend/internal/thumb"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// DefaultMaxUploadBytes applies when Handler.MaxUploadBytes is unset.
+const DefaultMaxUploadBytes = 10 << 20
+
+const thumbnailSize = 320
+
+// allowedUploadTypes is keyed by the sniffed media type; the client's
+// Content-Type is not trusted.
+var allowedUploadTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"application/zip": true, // also docx/xlsx/pptx
+	"text/plain":      true,
+	"audio/mpeg":      true,
+	"video/mp4":       true,
+}
+
+// thumbnailTypes are the image types the standard library can decode.
+var thumbnailTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true}
+
+func (h *Handler) maxUploadBytes() int64 {
+	if h.MaxUploadBytes > 0 {
+		return h.MaxUploadBytes
+	}
+	return DefaultMaxUploadBytes
+}
+
+// UploadAttachment stores a multipart "file" as a pending attachment of the
+// room. It is sent by passing its id in attachment_ids when creating a message.
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	max := h.maxUploadBytes()
+	tooLarge := gin.H{"error": fmt.Sprintf("file larger than %d bytes", max), "code": "VALIDATION_FAILED"}
+	// leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max+1<<20)
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if fh.Size > max {
+		c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unreadable file", "code": "VALIDATION_FAILED"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, max+1))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unreadable file", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if int64(len(data)) > max {
+		c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty", "code": "VALIDATION_FAILED"})
+		return
+	}
+	ctype, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !allowedUploadTypes[ctype] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported file type " + ctype, "code": "VALIDATION_FAILED"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	a := models.Attachment{
+		RoomID:      roomID,
+		UploaderID:  userID,
+		Filename:    cleanFilename(fh.Filename),
+		ContentType: ctype,
+		Size:        int64(len(data)),
+		StorageKey:  fmt.Sprintf("rooms/%d/%s", roomID, uuid.NewString()),
+	}
+	if err := h.Blobs.Put(ctx, a.StorageKey, bytes.NewReader(data), a.Size, ctype); err != nil {
+		log.Printf("[UploadAttachment] put room_id=%d: %v", roomID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "storage error", "code": "INTERNAL"})
+		return
+	}
+	if thumbnailTypes[ctype] {
+		h.addThumbnail(c, &a, data)
+	}
+
+	created, err := h.Store.CreateAttachment(ctx, a)
+	if err != nil {
+		log.Printf("[UploadAttachment] insert room_id=%d user_id=%d: %v", roomID, userID, err)
+		_ = h.Blobs.Delete(ctx, a.StorageKey)
+		if a.ThumbKey != nil {
+			_ = h.Blobs.Delete(ctx, *a.ThumbKey)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// addThumbnail records the image size and stores a thumbnail next to the
+// original. Failures only cost the preview, not the upload.
+func (h *Handler) addThumbnail(c *gin.Context, a *models.Attachment, data []byte) {
+	w, ht, err := thumb.Size(data)
+	if err != nil {
+		return
+	}
+	a.Width, a.Height = &w, &ht
+	out, err := thumb.Make(data, thumbnailSize)
+	if err != nil {
+		log.Printf("[UploadAttachment] thumbnail %s: %v", a.StorageKey, err)
+		return
+	}
+	key := a.StorageKey + ".thumb.jpg"
+	if err := h.Blobs.Put(c.Request.Context(), key, bytes.NewReader(out), int64(len(out)), "image/jpeg"); err != nil {
+		log.Printf("[UploadAttachment] put thumbnail %s: %v", key, err)
+		return
+	}
+	a.ThumbKey = &key
+}
+
+// DownloadAttachment streams an attachment to a member of its room.
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	a, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	disposition := "attachment"
+	if strings.HasPrefix(a.ContentType, "image/") {
+		disposition = "inline"
+	}
+	h.serveBlob(c, a.StorageKey, a.ContentType, a.Size, mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+}
+
+func (h *Handler) DownloadThumbnail(c *gin.Context) {
+	a, ok := h.attachment(c)
+	if !ok {
+		return
+	}
+	if a.ThumbKey == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no thumbnail", "code": "NOT_FOUND"})
+		return
+	}
+	h.serveBlob(c, *a.ThumbKey, "image/jpeg", -1, "inline")
+}
+
+func (h *Handler) attachment(c *gin.Context) (models.Attachment, bool) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return models.Attachment{}, false
+	}
+	attID, ok := parseIDParam(c, "attId", "invalid attachment id")
+	if !ok {
+		return models.Attachment{}, false
+	}
+	a, err := h.Store.GetAttachment(c.Request.Context(), roomID, attID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found", "code": "NOT_FOUND"})
+		return a, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return a, false
+	}
+	return a, true
+}
+
+func (h *Handler) serveBlob(c *gin.Context, key, ctype string, size int64, disposition string) {
+	rc, err := h.Blobs.Get(c.Request.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		log.Printf("[attachments] get %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "storage error", "code": "INTERNAL"})
+		return
+	}
+	defer rc.Close()
+	c.DataFromReader(http.StatusOK, size, ctype, rc, map[string]string{
+		"Content-Disposition":    disposition,
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+	})
+}
+
+// cleanFilename keeps the base name of a client-supplied path, without
+// control characters, at most 255 runes.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if r := []rune(name); len(r) > 255 {
+		name = string(r[:255])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
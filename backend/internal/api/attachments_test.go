@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func uploadRequest(t *testing.T, roomPath string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "upload.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, roomPath, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadAttachment_Rejections(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	cases := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"too large", append(png, bytes.Repeat([]byte{0}, 64)...), http.StatusRequestEntityTooLarge},
+		{"unsupported type", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType},
+		{"empty", nil, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		h, r, mock := setup(t)
+		h.MaxUploadBytes = 48
+		expectRoomAccess(mock, 1, true)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, uploadRequest(t, "/api/rooms/1/attachments", tc.data))
+
+		if w.Code != tc.want || errorCode(t, w) != "VALIDATION_FAILED" {
+			t.Fatalf("%s: want %d VALIDATION_FAILED, got %d %s", tc.name, tc.want, w.Code, w.Body.String())
+		}
+		// rejected before anything is stored
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: unmet sql expectations: %v", tc.name, err)
+		}
+	}
+}
+
+func TestDownloadAttachment_OnlyRoomMembers(t *testing.T) {
+	_, r, mock := setup(t)
+	// a private room the caller is not in answers as if it didn't exist
+	expectRoomAccess(mock, 1, false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/1/attachments/5", nil))
+
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "NOT_FOUND" {
+		t.Fatalf("want 404 NOT_FOUND, got %d %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDownloadAttachment_SomeoneElsesPendingUpload(t *testing.T) {
+	_, r, mock := setup(t)
+	expectRoomAccess(mock, 1, true)
+	mock.ExpectQuery(`FROM attachments a`).
+		WithArgs(int64(5), int64(1), int64(7)).
+		WillReturnRows(mock.NewRows([]string{"id"}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/1/attachments/5", nil))
+
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "NOT_FOUND" {
+		t.Fatalf("want 404 NOT_FOUND, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"backend/internal/cursor"
+	"backend/internal/models"
+	"backend/internal/store"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const bookmarksList = "bookmarks"
+
+// maxReminderAhead bounds how far in the future a reminder may be set.
+const maxReminderAhead = 365 * 24 * time.Hour
+
+type saveBookmarkReq struct {
+	RemindAt *time.Time `json:"remind_at"` // optional
+}
+
+// SaveBookmark: PUT /bookmarks/:msgId with an optional {remind_at}.
+// Saving again replaces the reminder.
+func (h *Handler) SaveBookmark(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	var req saveBookmarkReq
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.RemindAt != nil {
+		if d := time.Until(*req.RemindAt); d <= 0 || d > maxReminderAhead {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "remind_at must be within the next year", "code": "VALIDATION_FAILED"})
+			return
+		}
+	}
+
+	b, err := h.Store.SaveBookmark(c.Request.Context(), userID, msgID, req.RemindAt)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		log.Printf("[SaveBookmark] msg_id=%d user_id=%d: %v", msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// DeleteBookmark: DELETE /bookmarks/:msgId (idempotent).
+func (h *Handler) DeleteBookmark(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	if _, err := h.Store.DeleteBookmark(c.Request.Context(), userID, msgID); err != nil {
+		log.Printf("[DeleteBookmark] msg_id=%d user_id=%d: %v", msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListBookmarks: GET /bookmarks, newest first; older pages follow
+// page_info.older_cursor.
+func (h *Handler) ListBookmarks(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	pq, ok := h.parsePageQuery(c, cursor.Cursor{List: bookmarksList})
+	if !ok {
+		return
+	}
+	if pq.aroundID != nil || (pq.cur != nil && pq.cur.Dir != cursor.Before) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bookmarks only page backwards", "code": "VALIDATION_FAILED"})
+		return
+	}
+	var beforeTS *time.Time
+	var beforeID *int64
+	if pq.cur != nil {
+		beforeTS, beforeID = &pq.cur.TS, &pq.cur.ID
+	}
+	limit := pq.limit
+
+	// one extra row tells whether another page exists
+	items, err := h.Store.ListBookmarks(c.Request.Context(), userID, limit+1, beforeTS, beforeID)
+	if err != nil {
+		log.Printf("[ListBookmarks] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	resp := models.BookmarkResp{Items: items}
+	if len(items) > limit {
+		resp.Items = items[:limit]
+		last := resp.Items[limit-1]
+		resp.PageInfo = models.PageInfo{
+			OlderCursor: h.Cursors.Encode(cursor.Cursor{List: bookmarksList, Dir: cursor.Before, TS: last.CreatedAt, ID: last.Message.ID}),
+			HasMore:     true,
+		}
+	}
+	if resp.Items == nil {
+		resp.Items = []models.Bookmark{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
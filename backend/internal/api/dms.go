@@ -0,0 +1,77 @@
+package api
+
+import (
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDMParticipants caps group DMs, caller included.
+const maxDMParticipants = 9
+
+type openDMReq struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// OpenDM creates or reuses the DM room for the caller plus user_ids.
+func (h *Handler) OpenDM(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req openDMReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+
+	participants := []int64{userID}
+	seen := map[int64]bool{userID: true}
+	for _, id := range req.UserIDs {
+		if id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id", "code": "VALIDATION_FAILED"})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			participants = append(participants, id)
+		}
+	}
+	if len(participants) < 2 || len(participants) > maxDMParticipants {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dm needs 1..8 other users", "code": "VALIDATION_FAILED"})
+		return
+	}
+
+	conv, created, err := h.Store.GetOrCreateDM(c.Request.Context(), participants)
+	if err != nil {
+		if errors.Is(err, store.ErrUnknownUser) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown user", "code": "VALIDATION_FAILED"})
+			return
+		}
+		log.Printf("[OpenDM] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if created {
+		c.JSON(http.StatusCreated, conv)
+		return
+	}
+	c.JSON(http.StatusOK, conv)
+}
+
+// ListDMs returns the caller's conversations ordered by last activity.
+func (h *Handler) ListDMs(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	convs, err := h.Store.ListConversations(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, convs)
+}
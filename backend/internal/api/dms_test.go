@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func openDM(r http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/dms", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOpenDM_RejectsSelfDM(t *testing.T) {
+	_, r, mock := setup(t)
+
+	for _, body := range []string{`{"user_ids":[]}`, `{"user_ids":[7]}`, `{"user_ids":[7,7]}`} {
+		if w := openDM(r, body); w.Code != http.StatusBadRequest || errorCode(t, w) != "VALIDATION_FAILED" {
+			t.Fatalf("%s: want 400 VALIDATION_FAILED, got %d %s", body, w.Code, w.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestOpenDM_ReturnsExistingConversation(t *testing.T) {
+	_, r, mock := setup(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rooms \(name, is_public, kind, dm_key\)`).
+		WithArgs("7,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT id FROM rooms WHERE dm_key = \$1`).
+		WithArgs("7,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectQuery(`FROM rooms r(.+)WHERE r.id = \$1`).
+		WithArgs(int64(40)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "last_message_at", "participant_ids"}).
+			AddRow(40, time.Now(), nil, "{7,9}"))
+	mock.ExpectCommit()
+
+	w := openDM(r, `{"user_ids":[9]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d %s", w.Code, w.Body.String())
+	}
+	var conv struct {
+		RoomID int64 `json:"room_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &conv); err != nil || conv.RoomID != 40 {
+		t.Fatalf("bad body %s: %v", w.Body.String(), err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListMessages_AnotherPairsDM(t *testing.T) {
+	_, r, mock := setup(t)
+	// room 40 is the DM of users 8 and 9; 7 is not a member
+	expectRoomAccess(mock, 40, false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/40/messages", nil))
+
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "NOT_FOUND" {
+		t.Fatalf("want 404 NOT_FOUND, got %d %s", w.Code, w.Body.String())
+	}
+}
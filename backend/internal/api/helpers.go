@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func currentUserID(c *gin.Context) (int64, bool) {
+	uidAny, ok := c.Get("userID")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no user", "code": "UNAUTHORIZED"})
+		return 0, false
+	}
+	return uidAny.(int64), true
+}
+
+func parseIDParam(c *gin.Context, name, errMsg string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg, "code": "VALIDATION_FAILED"})
+		return 0, false
+	}
+	return id, true
+}
+
+// roomAccess resolves :id and the caller, and rejects rooms the caller cannot see.
+// Inaccessible rooms answer 404 so private rooms and DMs don't leak their existence.
+func (h *Handler) roomAccess(c *gin.Context) (roomID, userID int64, ok bool) {
+	if roomID, ok = parseIDParam(c, "id", "invalid room id"); !ok {
+		return 0, 0, false
+	}
+	if userID, ok = currentUserID(c); !ok {
+		return 0, 0, false
+	}
+	allowed, err := h.Store.CanAccessRoom(c.Request.Context(), roomID, userID)
+	if err != nil {
+		log.Printf("[roomAccess] room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return 0, 0, false
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found", "code": "NOT_FOUND"})
+		return 0, 0, false
+	}
+	return roomID, userID, true
+}
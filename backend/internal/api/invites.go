@@ -0,0 +1,172 @@
+package api
+
+import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createInviteReq struct {
+	Email          string `json:"email"`
+	MaxUses        int    `json:"max_uses"`         // 0: unlimited
+	ExpiresInHours int    `json:"expires_in_hours"` // 0: never
+}
+
+// createInvite validates the request body and issues inv (target and creator already set).
+func (h *Handler) createInvite(c *gin.Context, inv models.Invite) {
+	var req createInviteReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > 24*30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours 0..720", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.MaxUses < 0 || req.MaxUses > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses 0..1000", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		inv.ExpiresAt = &t
+	}
+	if req.MaxUses > 0 {
+		inv.MaxUses = &req.MaxUses
+	}
+	if req.Email != "" {
+		inv.Email = &req.Email
+	}
+
+	created, err := h.Store.CreateInvite(c.Request.Context(), inv)
+	if err != nil {
+		log.Printf("[CreateInvite] user_id=%d: %v", inv.CreatedBy, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		return
+	}
+	link := inviteLink(created.Code)
+	if created.Email != nil && h.Mailer != nil {
+		body := fmt.Sprintf("You have been invited to chatapp.\nJoin here: %s\n", link)
+		if err := h.Mailer.Send(c.Request.Context(), *created.Email, "You're invited to chatapp", body); err != nil {
+			log.Printf("[CreateInvite] mail to=%s: %v", *created.Email, err)
+		}
+	}
+	c.JSON(http.StatusCreated, gin.H{"invite": created, "link": link})
+}
+
+func inviteLink(code string) string {
+	base := os.Getenv("INVITE_BASE_URL")
+	if base == "" {
+		base = "http://localhost:5173/invite/"
+	}
+	return base + code
+}
+
+// roomAdmin is roomAccess plus an owner/admin role check.
+func (h *Handler) roomAdmin(c *gin.Context) (roomID, userID int64, ok bool) {
+	if roomID, userID, ok = h.roomAccess(c); !ok {
+		return
+	}
+	role, err := h.Store.RoomRole(c.Request.Context(), roomID, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return 0, 0, false
+	}
+	if role != models.RoleOwner && role != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "room admins only", "code": "FORBIDDEN"})
+		return 0, 0, false
+	}
+	return roomID, userID, true
+}
+
+func (h *Handler) CreateRoomInvite(c *gin.Context) {
+	roomID, userID, ok := h.roomAdmin(c)
+	if !ok {
+		return
+	}
+	h.createInvite(c, models.Invite{RoomID: &roomID, CreatedBy: userID})
+}
+
+func (h *Handler) ListRoomInvites(c *gin.Context) {
+	roomID, _, ok := h.roomAdmin(c)
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListRoomInvites(c.Request.Context(), roomID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) RevokeRoomInvite(c *gin.Context) {
+	roomID, _, ok := h.roomAdmin(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := parseIDParam(c, "inviteId", "invalid invite id")
+	if !ok {
+		return
+	}
+	err := h.Store.RevokeRoomInvite(c.Request.Context(), roomID, inviteID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ListInviteRedemptions(c *gin.Context) {
+	roomID, _, ok := h.roomAdmin(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := parseIDParam(c, "inviteId", "invalid invite id")
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListInviteRedemptions(c.Request.Context(), roomID, inviteID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// AcceptInvite joins the caller to the invite's workspace or room.
+func (h *Handler) AcceptInvite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	res, err := h.Store.AcceptInvite(c.Request.Context(), c.Param("code"), userID)
+	switch {
+	case errors.Is(err, store.ErrInviteInvalid):
+		c.JSON(http.StatusNotFound, gin.H{"error": "invite invalid or expired", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, store.ErrInviteEmailMismatch):
+		c.JSON(http.StatusForbidden, gin.H{"error": "invite issued to another email", "code": "FORBIDDEN"})
+		return
+	case err != nil:
+		log.Printf("[AcceptInvite] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
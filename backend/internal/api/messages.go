@@ -1,7 +1,11 @@
 package api
 
 import (
+	"backend/internal/cursor"
 	"backend/internal/models"
+	"backend/internal/msgsvc"
+	"backend/internal/store"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -11,72 +15,185 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ListMessages pages through the main timeline, newest first. Without a
+// cursor it starts at the newest message; page_info cursors continue in
+// either direction, and around_id centres the page on a message (limit
+// messages on each side).
 func (h *Handler) ListMessages(c *gin.Context) {
-	roomID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || roomID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id", "code": "VALIDATION_FAILED"})
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
 		return
 	}
+	h.listWindow(c, roomID, userID, nil)
+}
 
-	limit := 50
-	if v := c.Query("limit"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
-			limit = n
-		}
+// ListReplies pages through a thread with the same params as ListMessages.
+func (h *Handler) ListReplies(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+
+	exists, err := h.Store.RootMessageExists(c.Request.Context(), roomID, msgID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	}
+	h.listWindow(c, roomID, userID, &msgID)
+}
+
+// listWindow serves one page of the timeline (parentID nil) or a thread.
+// Every mode fetches one extra row to tell whether the page was cut short.
+func (h *Handler) listWindow(c *gin.Context, roomID, userID int64, parentID *int64) {
+	scope := cursor.Cursor{RoomID: roomID}
+	if parentID != nil {
+		scope.ParentID = *parentID
+	}
+	q, ok := h.parsePageQuery(c, scope)
+	if !ok {
+		return
 	}
 
 	var (
-		beforeTS *time.Time
-		beforeID *int64
+		items              []models.Message
+		hasOlder, hasNewer bool
+		err                error
+		ctx                = c.Request.Context()
 	)
-
-	if ts := c.Query("before_ts"); ts != "" {
-		if t, err := time.Parse(time.RFC3339, ts); err == nil {
-			beforeTS = &t
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before_ts", "code": "VALIDATION_FAILED"})
-			return
+	switch {
+	case q.aroundID != nil:
+		items, hasOlder, hasNewer, err = h.Store.ListMessagesAround(ctx, roomID, userID, parentID, *q.aroundID, q.limit)
+	case q.cur != nil && q.cur.Dir == cursor.After:
+		items, err = h.Store.ListMessagesAfter(ctx, roomID, userID, parentID, q.limit+1, q.cur.TS, q.cur.ID)
+		// newest first: the extra row is the head of the slice
+		if hasNewer = len(items) > q.limit; hasNewer {
+			items = items[1:]
 		}
-	}
-	if idStr := c.Query("before_id"); idStr != "" {
-		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
-			beforeID = &id
+		hasOlder = true
+	default:
+		var beforeTS *time.Time
+		var beforeID *int64
+		if q.cur != nil {
+			beforeTS, beforeID = &q.cur.TS, &q.cur.ID
+			hasNewer = true
+		}
+		if parentID == nil {
+			items, err = h.Store.ListMessages(ctx, roomID, userID, q.limit+1, beforeTS, beforeID)
 		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before_ts", "code": "VALIDATION_FAILED"})
-			return
+			items, err = h.Store.ListReplies(ctx, roomID, *parentID, userID, q.limit+1, beforeTS, beforeID)
+		}
+		if hasOlder = len(items) > q.limit; hasOlder {
+			items = items[:q.limit]
 		}
 	}
-	if (beforeTS == nil) != (beforeID == nil) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "before_ts & before_id must both present", "code": "VALIDATION_FAILED"})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
 		return
 	}
-
-	items, err := h.Store.ListMessages(c.Request.Context(), roomID, limit, beforeTS, beforeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
 		return
 	}
+	if items == nil {
+		items = []models.Message{}
+	}
 
-	resp := models.MessageResp{Items: items, PageInfo: models.PageInfo{HasMore: false}}
-	if len(items) == limit {
-		last := items[len(items)-1]
-		resp.PageInfo.NextBeforeTS = &last.CreatedAt
-		resp.PageInfo.NextBeforeID = &last.ID
-		if hasMore, err := h.Store.HasOlderMessages(c.Request.Context(), roomID, last.CreatedAt, last.ID); err == nil {
-			resp.PageInfo.HasMore = hasMore
+	resp := models.MessageResp{Items: items}
+	if len(items) > 0 {
+		first, last := items[0], items[len(items)-1]
+		if hasOlder {
+			resp.PageInfo.HasMore = true
+			resp.PageInfo.OlderCursor = h.Cursors.Encode(withPos(scope, cursor.Before, last.CreatedAt, last.ID))
+		}
+		if hasNewer {
+			resp.PageInfo.HasNewer = true
+			resp.PageInfo.NewerCursor = h.Cursors.Encode(withPos(scope, cursor.After, first.CreatedAt, first.ID))
 		}
 	}
 	c.JSON(http.StatusOK, resp)
 }
 
+func withPos(scope cursor.Cursor, dir cursor.Dir, ts time.Time, id int64) cursor.Cursor {
+	scope.Dir, scope.TS, scope.ID = dir, ts, id
+	return scope
+}
+
+type pageQuery struct {
+	limit    int
+	cur      *cursor.Cursor
+	aroundID *int64
+}
+
+// parsePageQuery reads limit, cursor and around_id. The cursor must verify
+// and belong to scope (same list, room and thread), so a token cannot be forged or
+// replayed against another list.
+func (h *Handler) parsePageQuery(c *gin.Context, scope cursor.Cursor) (pageQuery, bool) {
+	q := pageQuery{limit: 50}
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 100 {
+			q.limit = n
+		}
+	}
+	if v := c.Query("cursor"); v != "" {
+		cur, err := h.Cursors.Decode(v)
+		if err != nil || cur.List != scope.List || cur.RoomID != scope.RoomID || cur.ParentID != scope.ParentID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor", "code": "VALIDATION_FAILED"})
+			return q, false
+		}
+		q.cur = &cur
+	}
+	if v := c.Query("around_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid around_id", "code": "VALIDATION_FAILED"})
+			return q, false
+		}
+		if q.cur != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either cursor or around_id", "code": "VALIDATION_FAILED"})
+			return q, false
+		}
+		q.aroundID = &n
+	}
+	return q, true
+}
+
 type createMsgReq struct {
-	Content string `json:"content"`
+	Content   string `json:"content"`
+	ParentID  *int64 `json:"parent_id"`   // reply in this thread
+	ReplyToID *int64 `json:"reply_to_id"` // quote an earlier message
+	// AttachmentIDs are pending uploads; content may be empty when set.
+	AttachmentIDs []int64 `json:"attachment_ids"`
+	// TTLSeconds makes the message ephemeral: deleted for everyone after it.
+	TTLSeconds int `json:"ttl_seconds"`
+	// Poll makes the message a poll; content is the question.
+	Poll *newPollReq `json:"poll"`
+}
+
+type newPollReq struct {
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple"`
+	Anonymous bool       `json:"anonymous"`
+	ClosesAt  *time.Time `json:"closes_at"`
+}
+
+func (p *newPollReq) toStore() *store.NewPoll {
+	if p == nil {
+		return nil
+	}
+	return &store.NewPoll{Options: p.Options, Multiple: p.Multiple, Anonymous: p.Anonymous, ClosesAt: p.ClosesAt}
 }
 
 func (h *Handler) CreateMessage(c *gin.Context) {
-	roomID, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil || roomID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room id", "code": "VALIDATION_FAILED"})
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
 		return
 	}
 	var req createMsgReq
@@ -84,30 +201,91 @@ func (h *Handler) CreateMessage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
 		return
 	}
-	req.Content = trimEdges(req.Content)
-	if l := utf8.RuneCountInString(req.Content); l < 1 || l > 2000 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "content length 1..2000", "code": "VALIDATION_FAILED"})
+	m, err := h.Messages.Create(c.Request.Context(), msgsvc.Input{
+		RoomID: roomID, UserID: userID, Content: req.Content,
+		ParentID: req.ParentID, ReplyToID: req.ReplyToID,
+		AttachmentIDs: req.AttachmentIDs,
+		TTLSeconds:    req.TTLSeconds,
+		Poll:          req.Poll.toStore(),
+	})
+	var e *msgsvc.Error
+	if errors.As(err, &e) {
+		c.JSON(serviceStatus[e.Code], gin.H{"error": e.Msg, "code": e.Code})
 		return
 	}
+	if err != nil {
+		log.Printf("[CreateMessage] insert error room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, m)
+}
+
+// serviceStatus maps msgsvc rejection codes to HTTP statuses.
+var serviceStatus = map[string]int{
+	msgsvc.CodeValidation:  http.StatusBadRequest,
+	msgsvc.CodeNotFound:    http.StatusNotFound,
+	msgsvc.CodeRateLimited: http.StatusTooManyRequests,
+	msgsvc.CodeForbidden:   http.StatusForbidden,
+	msgsvc.CodeEditWindow:  http.StatusForbidden,
+}
 
-	uidAny, ok := c.Get("userID")
+type editMsgReq struct {
+	Content string `json:"content"`
+}
+
+func (h *Handler) EditMessage(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "no user", "code": "UNAUTHORIZED"})
 		return
 	}
-	userID := uidAny.(int64)
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	var req editMsgReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
 
-	m, err := h.Store.CreateMessage(c.Request.Context(), roomID, userID, req.Content)
+	m, err := h.Messages.Edit(c.Request.Context(), roomID, msgID, userID, req.Content)
+	var e *msgsvc.Error
+	if errors.As(err, &e) {
+		c.JSON(serviceStatus[e.Code], gin.H{"error": e.Msg, "code": e.Code})
+		return
+	}
 	if err != nil {
-		log.Printf("[CreateMessage] insert error room_id=%d user_id=%d: %v", roomID, userID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		log.Printf("[EditMessage] update error room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update error", "code": "INTERNAL"})
 		return
 	}
+	c.JSON(http.StatusOK, m)
+}
 
-	// WS hub broadcast
-	// if hBroadcaster != nil { hBroadcaster.BroadcastMessage(m) }
+func (h *Handler) DeleteMessage(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusCreated, m)
+	_, err := h.Messages.Delete(c.Request.Context(), roomID, msgID, userID)
+	var e *msgsvc.Error
+	if errors.As(err, &e) {
+		c.JSON(serviceStatus[e.Code], gin.H{"error": e.Msg, "code": e.Code})
+		return
+	}
+	if err != nil {
+		log.Printf("[DeleteMessage] delete error room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete error", "code": "INTERNAL"})
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
 func trimEdges(s string) string {
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"backend/internal/cursor"
+	"backend/internal/msgsvc"
+	"backend/internal/store"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// setup mounts the API with user 7 signed in and the store on sqlmock.
+func setup(t *testing.T) (*Handler, *gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	h := &Handler{
+		Store:   store.NewFormDB(db),
+		Cursors: &cursor.Codec{Key: []byte("test_secret")},
+	}
+	r := gin.New()
+	Mount(r.Group("/api"), h, func(c *gin.Context) { c.Set("userID", int64(7)) })
+	return h, r, mock
+}
+
+func expectRoomAccess(mock sqlmock.Sqlmock, roomID int64, visible bool) {
+	mock.ExpectQuery(`SELECT EXISTS \(\s*SELECT 1 FROM rooms vr`).
+		WithArgs(roomID, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(visible))
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("bad json %q: %v", w.Body.String(), err)
+	}
+	return body.Code
+}
+
+func TestListMessages_CursorFromAnotherRoom(t *testing.T) {
+	h, r, mock := setup(t)
+	// a valid, signed cursor for room 1 ...
+	token := h.Cursors.Encode(cursor.Cursor{RoomID: 1, Dir: cursor.Before, TS: time.Now(), ID: 10})
+	// ... replayed against room 2, which the caller can see
+	expectRoomAccess(mock, 2, true)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/2/messages?cursor="+url.QueryEscape(token), nil))
+
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "VALIDATION_FAILED" {
+		t.Fatalf("want 400 VALIDATION_FAILED, got %d %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListMessages_ThreadCursorOnTimeline(t *testing.T) {
+	h, r, mock := setup(t)
+	token := h.Cursors.Encode(cursor.Cursor{RoomID: 1, ParentID: 5, Dir: cursor.Before, TS: time.Now(), ID: 10})
+	expectRoomAccess(mock, 1, true)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/1/messages?cursor="+url.QueryEscape(token), nil))
+
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "VALIDATION_FAILED" {
+		t.Fatalf("want 400 VALIDATION_FAILED, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListMessages_HiddenRoom(t *testing.T) {
+	_, r, mock := setup(t)
+	expectRoomAccess(mock, 2, false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rooms/2/messages", nil))
+
+	if w.Code != http.StatusNotFound || errorCode(t, w) != "NOT_FOUND" {
+		t.Fatalf("want 404 NOT_FOUND, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestEditMessage_WindowClosed(t *testing.T) {
+	h, r, mock := setup(t)
+	h.Messages = msgsvc.Default(h.Store, nil, nil, nil)
+	expectRoomAccess(mock, 1, true)
+	// the service checks access again, as it does for every transport
+	expectRoomAccess(mock, 1, true)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM messages\s+WHERE id = \$1 AND room_id = \$2(.+)FOR UPDATE`).
+		WithArgs(int64(9), int64(1), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "room_id", "user_id", "parent_id", "reply_to_id", "content", "created_at", "edited_at", "deleted_at", "embeds", "expires_at"}).
+			AddRow(9, 1, 7, nil, nil, "old", time.Now().Add(-time.Hour), nil, nil, nil, nil))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/api/rooms/1/messages/9", strings.NewReader(`{"content":"new"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden || errorCode(t, w) != "EDIT_WINDOW_CLOSED" {
+		t.Fatalf("want 403 EDIT_WINDOW_CLOSED, got %d %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPins: GET /rooms/:id/pins.
+func (h *Handler) ListPins(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListPins(c.Request.Context(), roomID, userID)
+	if err != nil {
+		log.Printf("[ListPins] room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// PinMessage: PUT /rooms/:id/messages/:msgId/pin (idempotent, moderators only).
+func (h *Handler) PinMessage(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	p, added, err := h.Store.PinMessage(c.Request.Context(), roomID, msgID, userID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "only moderators can pin", "code": "FORBIDDEN"})
+		return
+	case errors.Is(err, store.ErrTooManyPins):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pin limit reached for this room", "code": "VALIDATION_FAILED"})
+		return
+	case err != nil:
+		log.Printf("[PinMessage] room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+
+	if added && h.Hub != nil {
+		h.Hub.BroadcastPinned(p)
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// UnpinMessage: DELETE /rooms/:id/messages/:msgId/pin (moderators only).
+func (h *Handler) UnpinMessage(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	removed, err := h.Store.UnpinMessage(c.Request.Context(), roomID, msgID, userID)
+	switch {
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "only moderators can unpin", "code": "FORBIDDEN"})
+		return
+	case err != nil:
+		log.Printf("[UnpinMessage] room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+
+	if removed && h.Hub != nil {
+		h.Hub.BroadcastUnpinned(roomID, msgID, userID)
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Vote: PUT /rooms/:id/messages/:msgId/poll/votes/:optionId (idempotent).
+func (h *Handler) Vote(c *gin.Context) {
+	h.setVote(c, true)
+}
+
+// Unvote: DELETE /rooms/:id/messages/:msgId/poll/votes/:optionId.
+func (h *Handler) Unvote(c *gin.Context) {
+	h.setVote(c, false)
+}
+
+func (h *Handler) setVote(c *gin.Context, add bool) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	optionID, ok := parseIDParam(c, "optionId", "invalid option id")
+	if !ok {
+		return
+	}
+
+	poll, changed, err := h.Store.Vote(c.Request.Context(), roomID, msgID, optionID, userID, add)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "poll not found", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot vote in this room", "code": "FORBIDDEN"})
+		return
+	case errors.Is(err, store.ErrPollClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "poll closed", "code": "CONFLICT"})
+		return
+	case errors.Is(err, store.ErrInvalidOption):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "option is not part of this poll", "code": "VALIDATION_FAILED"})
+		return
+	case err != nil:
+		log.Printf("[setVote] room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+
+	if changed && h.Hub != nil {
+		h.Hub.BroadcastPollUpdated(roomID, poll)
+	}
+	c.JSON(http.StatusOK, poll)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"backend/internal/models"
+	"backend/internal/presence"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxPresenceQuery = 100
+
+// GetPresence returns the status of each user in ?user_ids=1,2,3. Users who
+// share no workspace or visible room with the caller are left out.
+func (h *Handler) GetPresence(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, part := range strings.Split(c.Query("user_ids"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_ids", "code": "VALIDATION_FAILED"})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxPresenceQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids must list 1..100 ids", "code": "VALIDATION_FAILED"})
+		return
+	}
+	ids, err := h.Store.VisibleUserIDs(c.Request.Context(), userID, ids)
+	if err != nil {
+		log.Printf("[GetPresence] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	statuses, err := h.Presence.Get(c.Request.Context(), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "presence error", "code": "INTERNAL"})
+		return
+	}
+	out := make([]models.Presence, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, models.Presence{UserID: id, Status: statuses[id]})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+type setPresenceReq struct {
+	Status string `json:"status"`
+}
+
+// SetPresence sets a manual away or dnd status; online clears it.
+func (h *Handler) SetPresence(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req setPresenceReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	status, err := h.Presence.SetManual(c.Request.Context(), userID, req.Status)
+	if errors.Is(err, presence.ErrInvalidStatus) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be online, away or dnd", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "presence error", "code": "INTERNAL"})
+		return
+	}
+	p := models.Presence{UserID: userID, Status: status}
+	if h.Hub != nil && status != presence.StatusOffline {
+		if rooms, err := h.Store.MemberRoomIDs(c.Request.Context(), userID); err != nil {
+			log.Printf("[presence] rooms of user %d: %v", userID, err)
+		} else {
+			h.Hub.BroadcastPresence(rooms, p)
+		}
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddReaction: PUT /rooms/:id/messages/:msgId/reactions/:emoji (idempotent).
+func (h *Handler) AddReaction(c *gin.Context) {
+	h.setReaction(c, true)
+}
+
+// RemoveReaction: DELETE /rooms/:id/messages/:msgId/reactions/:emoji.
+func (h *Handler) RemoveReaction(c *gin.Context) {
+	h.setReaction(c, false)
+}
+
+func (h *Handler) setReaction(c *gin.Context, add bool) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	emoji := c.Param("emoji")
+
+	var (
+		changed bool
+		err     error
+	)
+	if add {
+		changed, err = h.Store.AddReaction(c.Request.Context(), roomID, msgID, userID, emoji)
+	} else {
+		changed, err = h.Store.RemoveReaction(c.Request.Context(), roomID, msgID, userID, emoji)
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, store.ErrInvalidEmoji):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid emoji", "code": "VALIDATION_FAILED"})
+		return
+	case errors.Is(err, store.ErrTooManyReactions):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many reactions on this message", "code": "VALIDATION_FAILED"})
+		return
+	case err != nil:
+		log.Printf("[setReaction] room_id=%d msg_id=%d user_id=%d: %v", roomID, msgID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+
+	if changed && h.Hub != nil {
+		h.Hub.BroadcastReaction(roomID, msgID, userID, emoji, add)
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,16 +1,131 @@
 package api
 
 import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 func (h *Handler) ListRooms(c *gin.Context) {
-	rooms, err := h.Store.ListRooms(c.Request.Context())
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	rooms, err := h.Store.ListRooms(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
 		return
 	}
 	c.JSON(http.StatusOK, rooms)
 }
+
+type setRoleReq struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SetRoomRole lets room owners and admins appoint admins, moderators and members.
+func (h *Handler) SetRoomRole(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	targetID, ok := parseIDParam(c, "userId", "invalid user id")
+	if !ok {
+		return
+	}
+	var req setRoleReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	switch req.Role {
+	case models.RoleAdmin, models.RoleModerator, models.RoleMember:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, moderator or member", "code": "VALIDATION_FAILED"})
+		return
+	}
+
+	err := h.Store.SetRoomRole(c.Request.Context(), roomID, userID, targetID, req.Role)
+	switch {
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to change this role", "code": "FORBIDDEN"})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in room", "code": "NOT_FOUND"})
+		return
+	case err != nil:
+		log.Printf("[SetRoomRole] room_id=%d user_id=%d target=%d: %v", roomID, userID, targetID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"room_id": roomID, "user_id": targetID, "role": req.Role})
+}
+
+type markReadReq struct {
+	MessageID *int64 `json:"message_id"` // omitted: newest message
+}
+
+// MarkRead moves the caller's read marker and syncs it to their other connections.
+func (h *Handler) MarkRead(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	var req markReadReq
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+			return
+		}
+	}
+
+	rm, moved, err := h.Store.MarkRoomRead(c.Request.Context(), roomID, userID, req.MessageID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		log.Printf("[MarkRead] room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if h.Hub != nil && moved {
+		h.Hub.SendToUser(userID, "room.read", rm)
+		h.queueReceipt(c, rm)
+	}
+	c.JSON(http.StatusOK, rm)
+}
+
+// queueReceipt shares the new read position with the room unless the user opted out.
+func (h *Handler) queueReceipt(c *gin.Context, rm models.ReadMarker) {
+	st, err := h.Store.GetSettings(c.Request.Context(), rm.UserID)
+	if err != nil || !st.ShareReadReceipts {
+		return
+	}
+	h.Hub.QueueReceipt(rm)
+}
+
+func (h *Handler) ListReceipts(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	msgID, ok := parseIDParam(c, "msgId", "invalid message id")
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListReceipts(c.Request.Context(), roomID, msgID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
@@ -1,17 +1,79 @@
 package api
 
 import (
+	"backend/internal/blob"
+	"backend/internal/cursor"
+	"backend/internal/mail"
+	"backend/internal/msgsvc"
+	"backend/internal/presence"
 	"backend/internal/store"
+	"backend/internal/ws"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	Store *store.Store
+	Store    *store.Store
+	Hub      *ws.Hub
+	Mailer   mail.Sender
+	Presence *presence.Tracker
+	Cursors  *cursor.Codec // signs pagination cursors
+
+	Blobs          blob.Storage // attachment bytes
+	MaxUploadBytes int64        // 0: DefaultMaxUploadBytes
+
+	Messages *msgsvc.Service // create, edit and delete pipeline
 }
 
 func Mount(g *gin.RouterGroup, h *Handler, authMW gin.HandlerFunc) {
-	g.GET("/rooms", h.ListRooms)
-	g.GET("/rooms/:id/messages", h.ListMessages)
+	g.GET("/rooms", authMW, h.ListRooms)
+	g.POST("/rooms/:id/read", authMW, h.MarkRead)
+	g.PUT("/rooms/:id/members/:userId/role", authMW, h.SetRoomRole)
+	g.GET("/rooms/:id/messages", authMW, h.ListMessages)
 	g.POST("/rooms/:id/messages", authMW, h.CreateMessage)
+	g.GET("/rooms/:id/messages/:msgId/replies", authMW, h.ListReplies)
+	g.GET("/rooms/:id/messages/:msgId/receipts", authMW, h.ListReceipts)
+	g.PATCH("/rooms/:id/messages/:msgId", authMW, h.EditMessage)
+	g.DELETE("/rooms/:id/messages/:msgId", authMW, h.DeleteMessage)
+	g.PUT("/rooms/:id/messages/:msgId/reactions/:emoji", authMW, h.AddReaction)
+	g.DELETE("/rooms/:id/messages/:msgId/reactions/:emoji", authMW, h.RemoveReaction)
+	g.PUT("/rooms/:id/messages/:msgId/poll/votes/:optionId", authMW, h.Vote)
+	g.DELETE("/rooms/:id/messages/:msgId/poll/votes/:optionId", authMW, h.Unvote)
+	g.PUT("/rooms/:id/messages/:msgId/pin", authMW, h.PinMessage)
+	g.DELETE("/rooms/:id/messages/:msgId/pin", authMW, h.UnpinMessage)
+	g.GET("/rooms/:id/pins", authMW, h.ListPins)
+	g.POST("/rooms/:id/scheduled", authMW, h.ScheduleMessage)
+	g.POST("/rooms/:id/attachments", authMW, h.UploadAttachment)
+	g.GET("/rooms/:id/attachments/:attId", authMW, h.DownloadAttachment)
+	g.GET("/rooms/:id/attachments/:attId/thumbnail", authMW, h.DownloadThumbnail)
+
+	g.GET("/search/messages", authMW, h.SearchMessages)
+	g.GET("/mentions", authMW, h.ListMentions)
+	g.GET("/bookmarks", authMW, h.ListBookmarks)
+	g.PUT("/bookmarks/:msgId", authMW, h.SaveBookmark)
+	g.DELETE("/bookmarks/:msgId", authMW, h.DeleteBookmark)
+	g.GET("/scheduled", authMW, h.ListScheduled)
+	g.PATCH("/scheduled/:schedId", authMW, h.EditScheduled)
+	g.DELETE("/scheduled/:schedId", authMW, h.CancelScheduled)
+	g.GET("/settings", authMW, h.GetSettings)
+	g.PATCH("/settings", authMW, h.UpdateSettings)
+	g.GET("/presence", authMW, h.GetPresence)
+	g.PUT("/presence", authMW, h.SetPresence)
+
+	g.POST("/dms", authMW, h.OpenDM)
+	g.GET("/dms", authMW, h.ListDMs)
+
+	g.POST("/workspaces", authMW, h.CreateWorkspace)
+	g.GET("/workspaces", authMW, h.ListWorkspaces)
+	g.GET("/workspaces/:id/members", authMW, h.ListWorkspaceMembers)
+	g.DELETE("/workspaces/:id/members/:userId", authMW, h.RemoveWorkspaceMember)
+	g.GET("/workspaces/:id/rooms", authMW, h.ListWorkspaceRooms)
+	g.POST("/workspaces/:id/rooms", authMW, h.CreateWorkspaceRoom)
+	g.POST("/workspaces/:id/invites", authMW, h.CreateWorkspaceInvite)
+
+	g.POST("/rooms/:id/invites", authMW, h.CreateRoomInvite)
+	g.GET("/rooms/:id/invites", authMW, h.ListRoomInvites)
+	g.DELETE("/rooms/:id/invites/:inviteId", authMW, h.RevokeRoomInvite)
+	g.GET("/rooms/:id/invites/:inviteId/redemptions", authMW, h.ListInviteRedemptions)
+	g.POST("/invites/:code/accept", authMW, h.AcceptInvite)
 }
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"backend/internal/msgsvc"
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxScheduleAhead bounds how far in the future a message may be scheduled.
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+type scheduleMsgReq struct {
+	Content   string    `json:"content"`
+	SendAt    time.Time `json:"send_at"`
+	ParentID  *int64    `json:"parent_id"`
+	ReplyToID *int64    `json:"reply_to_id"`
+}
+
+func validSendAt(t time.Time) bool {
+	d := time.Until(t)
+	return d > 0 && d <= maxScheduleAhead
+}
+
+// ScheduleMessage: POST /rooms/:id/scheduled. The content goes through the
+// same checks as a message sent now; the dispatcher posts it at send_at and
+// checks thread and reply targets then.
+func (h *Handler) ScheduleMessage(c *gin.Context) {
+	roomID, userID, ok := h.roomAccess(c)
+	if !ok {
+		return
+	}
+	var req scheduleMsgReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if !validSendAt(req.SendAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "send_at must be within the next year", "code": "VALIDATION_FAILED"})
+		return
+	}
+	in, err := h.Messages.Validate(c.Request.Context(), msgsvc.Input{
+		RoomID: roomID, UserID: userID, Content: req.Content,
+		ParentID: req.ParentID, ReplyToID: req.ReplyToID,
+	})
+	var e *msgsvc.Error
+	if errors.As(err, &e) {
+		c.JSON(serviceStatus[e.Code], gin.H{"error": e.Msg, "code": e.Code})
+		return
+	}
+	if err != nil {
+		log.Printf("[ScheduleMessage] validate room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+
+	sm, err := h.Store.CreateScheduled(c.Request.Context(), store.NewMessage{
+		RoomID: roomID, UserID: userID, Content: in.Content,
+		ParentID: in.ParentID, ReplyToID: in.ReplyToID,
+	}, req.SendAt)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found", "code": "NOT_FOUND"})
+		return
+	case errors.Is(err, store.ErrTooManyScheduled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many scheduled messages", "code": "VALIDATION_FAILED"})
+		return
+	case err != nil:
+		log.Printf("[ScheduleMessage] room_id=%d user_id=%d: %v", roomID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusCreated, sm)
+}
+
+// ListScheduled: GET /scheduled[?room_id=] lists the caller's pending and
+// failed scheduled messages.
+func (h *Handler) ListScheduled(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var roomID *int64
+	if v := c.Query("room_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room_id", "code": "VALIDATION_FAILED"})
+			return
+		}
+		roomID = &n
+	}
+	items, err := h.Store.ListScheduled(c.Request.Context(), userID, roomID)
+	if err != nil {
+		log.Printf("[ListScheduled] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+type editScheduledReq struct {
+	Content *string    `json:"content"`
+	SendAt  *time.Time `json:"send_at"`
+}
+
+// EditScheduled: PATCH /scheduled/:schedId, only while pending.
+func (h *Handler) EditScheduled(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "schedId", "invalid scheduled message id")
+	if !ok {
+		return
+	}
+	var req editScheduledReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.SendAt != nil && !validSendAt(*req.SendAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "send_at must be within the next year", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if req.Content != nil {
+		sm, err := h.Store.GetScheduled(c.Request.Context(), userID, id)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "scheduled message not found", "code": "NOT_FOUND"})
+			return
+		}
+		if err != nil {
+			log.Printf("[EditScheduled] id=%d user_id=%d: %v", id, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+			return
+		}
+		in, err := h.Messages.Validate(c.Request.Context(), msgsvc.Input{
+			RoomID: sm.RoomID, UserID: userID, Content: *req.Content,
+			ParentID: sm.ParentID, ReplyToID: sm.ReplyToID,
+		})
+		var e *msgsvc.Error
+		if errors.As(err, &e) {
+			c.JSON(serviceStatus[e.Code], gin.H{"error": e.Msg, "code": e.Code})
+			return
+		}
+		if err != nil {
+			log.Printf("[EditScheduled] validate id=%d user_id=%d: %v", id, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+			return
+		}
+		req.Content = &in.Content
+	}
+
+	sm, err := h.Store.UpdateScheduled(c.Request.Context(), userID, id, req.Content, req.SendAt)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scheduled message not found", "code": "NOT_FOUND"})
+		return
+	}
+	if err != nil {
+		log.Printf("[EditScheduled] id=%d user_id=%d: %v", id, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, sm)
+}
+
+// CancelScheduled: DELETE /scheduled/:schedId.
+func (h *Handler) CancelScheduled(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "schedId", "invalid scheduled message id")
+	if !ok {
+		return
+	}
+	removed, err := h.Store.CancelScheduled(c.Request.Context(), userID, id)
+	if err != nil {
+		log.Printf("[CancelScheduled] id=%d user_id=%d: %v", id, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scheduled message not found", "code": "NOT_FOUND"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
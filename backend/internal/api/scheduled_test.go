@@ -0,0 +1,30 @@
+package api
+
+import (
+	"backend/internal/msgsvc"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleMessage_UsesMessageValidation(t *testing.T) {
+	h, r, mock := setup(t)
+	h.Messages = msgsvc.Default(h.Store, nil, nil, nil)
+	expectRoomAccess(mock, 1, true)
+
+	body := `{"content":"   ","send_at":"` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/rooms/1/scheduled", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || errorCode(t, w) != "VALIDATION_FAILED" {
+		t.Fatalf("want 400 VALIDATION_FAILED, got %d %s", w.Code, w.Body.String())
+	}
+	// rejected before anything is stored
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"backend/internal/cursor"
+	"backend/internal/models"
+	"backend/internal/search"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const searchList = "search"
+
+// SearchMessages runs a full-text search over the rooms the caller can see.
+// q supports "phrases", from:user, in:room, before:/after: dates; older pages
+// follow page_info.older_cursor like ListMessages.
+func (h *Handler) SearchMessages(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	q, err := search.Parse(c.Query("q"))
+	if errors.Is(err, search.ErrEmptyQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q needs search terms", "code": "VALIDATION_FAILED"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "VALIDATION_FAILED"})
+		return
+	}
+	// search spans rooms, so its cursors name the list instead
+	pq, ok := h.parsePageQuery(c, cursor.Cursor{List: searchList})
+	if !ok {
+		return
+	}
+	if pq.aroundID != nil || (pq.cur != nil && pq.cur.Dir != cursor.Before) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "search only pages backwards", "code": "VALIDATION_FAILED"})
+		return
+	}
+	var beforeTS *time.Time
+	var beforeID *int64
+	if pq.cur != nil {
+		beforeTS, beforeID = &pq.cur.TS, &pq.cur.ID
+	}
+	limit := pq.limit
+
+	// one extra row tells whether another page exists
+	hits, err := h.Store.SearchMessages(c.Request.Context(), userID, q, limit+1, beforeTS, beforeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	resp := models.SearchResp{Items: hits}
+	if len(hits) > limit {
+		resp.Items = hits[:limit]
+		last := resp.Items[limit-1].Message
+		resp.PageInfo = models.PageInfo{
+			OlderCursor: h.Cursors.Encode(cursor.Cursor{List: searchList, Dir: cursor.Before, TS: last.CreatedAt, ID: last.ID}),
+			HasMore:     true,
+		}
+	}
+	if resp.Items == nil {
+		resp.Items = []models.SearchHit{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
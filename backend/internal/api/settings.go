@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	st, err := h.Store.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
+
+type updateSettingsReq struct {
+	ShareReadReceipts *bool `json:"share_read_receipts"`
+}
+
+// UpdateSettings applies the fields present in the body.
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req updateSettingsReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	st, err := h.Store.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if req.ShareReadReceipts != nil {
+		st.ShareReadReceipts = *req.ShareReadReceipts
+	}
+	if st, err = h.Store.UpdateSettings(c.Request.Context(), userID, st); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, st)
+}
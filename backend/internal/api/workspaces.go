@@ -0,0 +1,187 @@
+package api
+
+import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"errors"
+	"log"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// workspaceMember resolves :id and the caller's role; non-members get 404.
+func (h *Handler) workspaceMember(c *gin.Context) (workspaceID, userID int64, role string, ok bool) {
+	if workspaceID, ok = parseIDParam(c, "id", "invalid workspace id"); !ok {
+		return
+	}
+	if userID, ok = currentUserID(c); !ok {
+		return
+	}
+	role, err := h.Store.WorkspaceRole(c.Request.Context(), workspaceID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found", "code": "NOT_FOUND"})
+		return 0, 0, "", false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return 0, 0, "", false
+	}
+	return workspaceID, userID, role, true
+}
+
+func canManageWorkspace(role string) bool {
+	return role == models.RoleOwner || role == models.RoleAdmin
+}
+
+type createWorkspaceReq struct {
+	Name string `json:"name"`
+}
+
+func (h *Handler) CreateWorkspace(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req createWorkspaceReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	req.Name = trimEdges(req.Name)
+	if l := utf8.RuneCountInString(req.Name); l < 1 || l > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name length 1..64", "code": "VALIDATION_FAILED"})
+		return
+	}
+
+	w, err := h.Store.CreateWorkspace(c.Request.Context(), req.Name, userID)
+	if err != nil {
+		log.Printf("[CreateWorkspace] user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *Handler) ListWorkspaces(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListWorkspaces(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) ListWorkspaceMembers(c *gin.Context) {
+	workspaceID, userID, _, ok := h.workspaceMember(c)
+	if !ok {
+		return
+	}
+	items, err := h.Store.ListWorkspaceMembers(c.Request.Context(), workspaceID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *Handler) ListWorkspaceRooms(c *gin.Context) {
+	workspaceID, userID, _, ok := h.workspaceMember(c)
+	if !ok {
+		return
+	}
+	rooms, err := h.Store.ListWorkspaceRooms(c.Request.Context(), workspaceID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusOK, rooms)
+}
+
+type createRoomReq struct {
+	Name     string `json:"name"`
+	IsPublic *bool  `json:"is_public"`
+}
+
+func (h *Handler) CreateWorkspaceRoom(c *gin.Context) {
+	workspaceID, userID, _, ok := h.workspaceMember(c)
+	if !ok {
+		return
+	}
+	var req createRoomReq
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json", "code": "VALIDATION_FAILED"})
+		return
+	}
+	req.Name = trimEdges(req.Name)
+	if l := utf8.RuneCountInString(req.Name); l < 1 || l > 64 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name length 1..64", "code": "VALIDATION_FAILED"})
+		return
+	}
+	isPublic := true
+	if req.IsPublic != nil {
+		isPublic = *req.IsPublic
+	}
+
+	room, err := h.Store.CreateWorkspaceRoom(c.Request.Context(), workspaceID, userID, req.Name, isPublic)
+	switch {
+	case errors.Is(err, store.ErrNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "room name already taken", "code": "CONFLICT"})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "workspace not found", "code": "NOT_FOUND"})
+		return
+	case err != nil:
+		log.Printf("[CreateWorkspaceRoom] workspace_id=%d user_id=%d: %v", workspaceID, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "insert error", "code": "INTERNAL"})
+		return
+	}
+	c.JSON(http.StatusCreated, room)
+}
+
+// CreateWorkspaceInvite issues a shareable link, or an email-bound invite when email is set.
+func (h *Handler) CreateWorkspaceInvite(c *gin.Context) {
+	workspaceID, userID, role, ok := h.workspaceMember(c)
+	if !ok {
+		return
+	}
+	if !canManageWorkspace(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admins only", "code": "FORBIDDEN"})
+		return
+	}
+	h.createInvite(c, models.Invite{WorkspaceID: &workspaceID, CreatedBy: userID})
+}
+
+// RemoveWorkspaceMember removes a member, or lets the caller leave, and drops
+// the removed user's live connections so they lose the workspace's rooms now.
+func (h *Handler) RemoveWorkspaceMember(c *gin.Context) {
+	workspaceID, userID, _, ok := h.workspaceMember(c)
+	if !ok {
+		return
+	}
+	targetID, ok := parseIDParam(c, "userId", "invalid user id")
+	if !ok {
+		return
+	}
+
+	err := h.Store.RemoveWorkspaceMember(c.Request.Context(), workspaceID, userID, targetID)
+	switch {
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to remove this member", "code": "FORBIDDEN"})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found in workspace", "code": "NOT_FOUND"})
+		return
+	case err != nil:
+		log.Printf("[RemoveWorkspaceMember] workspace_id=%d user_id=%d target=%d: %v", workspaceID, userID, targetID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	h.Hub.DisconnectUser(targetID)
+	c.Status(http.StatusNoContent)
+}
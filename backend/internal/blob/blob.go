@@ -0,0 +1,18 @@
+// Package blob stores attachment bytes behind a small interface, with a
+// local-filesystem backend and an S3-compatible one.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound for missing keys. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,43 @@
+package cursor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	c := &Codec{Key: []byte("k1")}
+	in := Cursor{List: "x", RoomID: 3, ParentID: 9, Dir: After, TS: time.Date(2026, 5, 1, 12, 0, 0, 123456000, time.UTC), ID: 42}
+
+	out, err := c.Decode(c.Encode(in))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.List != in.List || out.RoomID != in.RoomID || out.ParentID != in.ParentID || out.Dir != in.Dir || !out.TS.Equal(in.TS) || out.ID != in.ID {
+		t.Fatalf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestCodec_RejectsTampering(t *testing.T) {
+	c := &Codec{Key: []byte("k1")}
+	tok := c.Encode(Cursor{RoomID: 3, Dir: Before, TS: time.Now(), ID: 42})
+	p, sig, _ := strings.Cut(tok, ".")
+
+	forged := (&Codec{Key: []byte("k1")}).Encode(Cursor{RoomID: 4, Dir: Before, TS: time.Now(), ID: 42})
+	fp, _, _ := strings.Cut(forged, ".")
+
+	cases := map[string]string{
+		"empty":         "",
+		"no signature":  p,
+		"swapped body":  fp + "." + sig,
+		"other key":     (&Codec{Key: []byte("k2")}).Encode(Cursor{RoomID: 3, Dir: Before, TS: time.Now(), ID: 42}),
+		"bad base64":    p + ".!!",
+		"truncated sig": p + "." + sig[:10],
+	}
+	for name, tok := range cases {
+		if _, err := c.Decode(tok); err != ErrInvalid {
+			t.Errorf("%s: want ErrInvalid, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"backend/internal/blob"
+	"backend/internal/store"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const attachmentCleanupBatch = 100
+
+// RunAttachmentCleanup deletes the files, then the rows, of attachments
+// whose message is gone and of uploads not sent within staleAfter, checking
+// every interval until ctx is cancelled.
+func RunAttachmentCleanup(ctx context.Context, st *store.Store, blobs blob.Storage, staleAfter, interval time.Duration) {
+	remove := blobRemover(ctx, blobs)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		staleBefore := time.Now().Add(-staleAfter)
+		for {
+			n, err := st.CleanupAttachments(ctx, staleBefore, attachmentCleanupBatch, remove)
+			if err != nil {
+				log.Printf("[attachments] cleanup: %v", err)
+				break
+			}
+			if n > 0 {
+				log.Printf("[attachments] removed %d attachments", n)
+			}
+			if n < attachmentCleanupBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// blobRemover deletes the files under keys; ones already gone don't count as
+// failures.
+func blobRemover(ctx context.Context, blobs blob.Storage) func(keys []string) error {
+	return func(keys []string) error {
+		for _, key := range keys {
+			if err := blobs.Delete(ctx, key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"backend/internal/blob"
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+	"log"
+	"time"
+)
+
+const expiryBatch = 500
+
+// RunEphemeralSweep soft-deletes expired ephemeral messages every interval
+// until ctx is cancelled, passing each tombstone to deleted (the hub's
+// message.deleted broadcast). Timelines hide them from the moment they
+// expire; the sweep makes it final for everyone, attachment files in blobs
+// included.
+func RunEphemeralSweep(ctx context.Context, st *store.Store, blobs blob.Storage, deleted func(models.Message), interval time.Duration) {
+	remove := blobRemover(ctx, blobs)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for {
+			ms, err := st.ExpireMessages(ctx, time.Now(), expiryBatch, remove)
+			if err != nil {
+				log.Printf("[expiry] sweep: %v", err)
+				break
+			}
+			for _, m := range ms {
+				deleted(m)
+			}
+			if len(ms) < expiryBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
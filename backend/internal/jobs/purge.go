@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"backend/internal/store"
+	"context"
+	"log"
+	"time"
+)
+
+const purgeBatch = 500
+
+// RunTombstonePurge hard-deletes soft-deleted messages older than retention,
+// checking every interval until ctx is cancelled.
+func RunTombstonePurge(ctx context.Context, st *store.Store, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		cutoff := time.Now().Add(-retention)
+		for {
+			n, err := st.PurgeDeletedMessages(ctx, cutoff, purgeBatch)
+			if err != nil {
+				log.Printf("[purge] tombstones: %v", err)
+				break
+			}
+			if n > 0 {
+				log.Printf("[purge] removed %d tombstones", n)
+			}
+			if n < purgeBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
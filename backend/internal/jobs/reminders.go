@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+	"log"
+	"time"
+)
+
+const reminderBatch = 200
+
+// RunBookmarkReminders delivers due bookmark reminders every interval until
+// ctx is cancelled. A reminder is sent once; users with no live connection
+// at that moment see it as reminded_at in their bookmark list.
+func RunBookmarkReminders(ctx context.Context, st *store.Store, deliver func(models.Bookmark), interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for {
+			due, err := st.ClaimDueReminders(ctx, time.Now(), reminderBatch)
+			if err != nil {
+				log.Printf("[reminders] claim: %v", err)
+				break
+			}
+			for _, b := range due {
+				deliver(b)
+			}
+			if len(due) < reminderBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const scheduledPruneBatch = 500
+
+// RunScheduledDispatch posts due scheduled messages every interval until ctx
+// is cancelled. publish fans a posted message out (broadcast, mentions,
+// previews) once it is committed; failed gets the ones that could not be
+// posted, so their author learns why.
+func RunScheduledDispatch(ctx context.Context, st *store.Store, publish func(context.Context, models.Message) error, failed func(models.ScheduledMessage), interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for ctx.Err() == nil {
+			sm, m, err := st.DispatchScheduled(ctx, time.Now())
+			if errors.Is(err, store.ErrNotFound) {
+				break
+			}
+			if err != nil {
+				log.Printf("[scheduled] dispatch: %v", err)
+				break
+			}
+			if sm.Status == models.ScheduledFailed {
+				log.Printf("[scheduled] id=%d not sent: %s", sm.ID, sm.Error)
+				failed(sm)
+				continue
+			}
+			if err := publish(ctx, m); err != nil {
+				log.Printf("[scheduled] publish msg_id=%d: %v", m.ID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunScheduledPrune deletes scheduled messages sent more than retention ago,
+// checking every interval until ctx is cancelled.
+func RunScheduledPrune(ctx context.Context, st *store.Store, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		cutoff := time.Now().Add(-retention)
+		for {
+			n, err := st.PruneSentScheduled(ctx, cutoff, scheduledPruneBatch)
+			if err != nil {
+				log.Printf("[scheduled] prune: %v", err)
+				break
+			}
+			if n > 0 {
+				log.Printf("[scheduled] pruned %d sent messages", n)
+			}
+			if n < scheduledPruneBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
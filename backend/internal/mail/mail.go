@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"context"
+	"log"
+)
+
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogSender writes mails to the log; used until an SMTP/provider sender is configured.
+type LogSender struct{}
+
+func (LogSender) Send(_ context.Context, to, subject, body string) error {
+	log.Printf("[mail] to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// ToHTML renders src as safe HTML: only the subset's tags are produced and
+// all text and attribute values are escaped.
+func ToHTML(src string) string {
+	var b strings.Builder
+	render(&b, Parse(src))
+	return b.String()
+}
+
+func render(b *strings.Builder, nodes []Node) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case KindParagraph:
+			b.WriteString("<p>")
+			render(b, n.Children)
+			b.WriteString("</p>")
+		case KindQuote:
+			b.WriteString("<blockquote>")
+			render(b, n.Children)
+			b.WriteString("</blockquote>")
+		case KindCodeBlock:
+			b.WriteString("<pre><code")
+			if n.Lang != "" {
+				b.WriteString(` class="language-` + html.EscapeString(n.Lang) + `"`)
+			}
+			b.WriteString(">" + html.EscapeString(n.Text) + "</code></pre>")
+		case KindText:
+			b.WriteString(html.EscapeString(n.Text))
+		case KindBold:
+			b.WriteString("<strong>")
+			render(b, n.Children)
+			b.WriteString("</strong>")
+		case KindItalic:
+			b.WriteString("<em>")
+			render(b, n.Children)
+			b.WriteString("</em>")
+		case KindCode:
+			b.WriteString("<code>" + html.EscapeString(n.Text) + "</code>")
+		case KindLink:
+			b.WriteString(`<a href="` + html.EscapeString(n.URL) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+			render(b, n.Children)
+			b.WriteString("</a>")
+		case KindBreak:
+			b.WriteString("<br>")
+		}
+	}
+}
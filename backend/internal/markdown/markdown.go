@@ -0,0 +1,48 @@
+// Package markdown implements the message formatting subset: **bold**,
+// *italic* / _italic_, `code`, ``` code blocks ```, [links](https://…) and
+// > quotes. Everything else is plain text, escaped when rendered.
+package markdown
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the longest message content, in runes.
+const MaxLength = 2000
+
+var (
+	ErrEmpty   = errors.New("content is empty")
+	ErrTooLong = errors.New("content is too long")
+)
+
+// Clean is the single content rule for every entry point: newlines become
+// \n, control characters other than \n and \t are dropped, surrounding
+// whitespace is trimmed and the result must hold 1..MaxLength runes (0 when
+// allowEmpty, e.g. for attachment-only messages).
+func Clean(content string, allowEmpty bool) (string, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\n' || r == '\t':
+			return r
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, content)
+	content = strings.TrimFunc(content, unicode.IsSpace)
+
+	n := utf8.RuneCountInString(content)
+	if n == 0 && !allowEmpty {
+		return "", ErrEmpty
+	}
+	if n > MaxLength {
+		return "", ErrTooLong
+	}
+	return content, nil
+}
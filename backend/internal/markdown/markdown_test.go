@@ -0,0 +1,123 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+func TestClean(t *testing.T) {
+	cases := []struct {
+		in, want   string
+		allowEmpty bool
+		err        error
+	}{
+		{in: "  hi\r\nthere\t\n", want: "hi\nthere"},
+		{in: "a\x00b\x1bc", want: "abc"},
+		{in: " \n\t ", err: ErrEmpty},
+		{in: " \n\t ", allowEmpty: true, want: ""},
+		{in: strings.Repeat("界", MaxLength), want: strings.Repeat("界", MaxLength)},
+		{in: strings.Repeat("界", MaxLength+1), err: ErrTooLong},
+	}
+	for _, c := range cases {
+		got, err := Clean(c.in, c.allowEmpty)
+		if err != c.err || got != c.want {
+			t.Errorf("Clean(%q) = %q, %v; want %q, %v", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestToHTML(t *testing.T) {
+	cases := map[string]string{
+		"**bold** and *it* and _it_":           "<p><strong>bold</strong> and <em>it</em> and <em>it</em></p>",
+		"snake_case_name stays":                "<p>snake_case_name stays</p>",
+		"`<b>` \\*x\\*":                        "<p><code>&lt;b&gt;</code> *x*</p>",
+		"<script>alert(1)</script>":            "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		"[site](https://x.example/?a=1&b=\"2)": `<p>[site](https://x.example/?a=1&amp;b=&#34;2)</p>`,
+		"[site](https://x.example/?a=1&b=2)":   `<p><a href="https://x.example/?a=1&amp;b=2" rel="nofollow noopener noreferrer" target="_blank">site</a></p>`,
+		"[x](javascript:alert(1))":             "<p>[x](javascript:alert(1))</p>",
+		"> quoted\n> **two**\nafter":           "<blockquote>quoted<br><strong>two</strong></blockquote><p>after</p>",
+		"```go\nfmt.Println(\"<hi>\")\n```":    `<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>`,
+		"one\ntwo\n\nthree":                    "<p>one<br>two</p><p>three</p>",
+		"**unclosed *mixed":                    "<p>**unclosed *mixed</p>",
+	}
+	for in, want := range cases {
+		if got := ToHTML(in); got != want {
+			t.Errorf("ToHTML(%q)\n got %s\nwant %s", in, got, want)
+		}
+	}
+}
+
+var allowedTags = map[string]bool{
+	"p": true, "blockquote": true, "pre": true, "code": true, "strong": true, "em": true, "a": true, "br": true,
+}
+
+// checkSafe fails unless out only uses the subset's tags and attributes and
+// every link targets http(s) or mailto.
+func checkSafe(t *testing.T, in, out string) {
+	t.Helper()
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return
+		}
+		if tt != html.StartTagToken && tt != html.EndTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		if !allowedTags[string(name)] {
+			t.Fatalf("input %q produced tag <%s>: %s", in, name, out)
+		}
+		for hasAttr {
+			var k, v []byte
+			k, v, hasAttr = z.TagAttr()
+			switch string(k) {
+			case "href":
+				l := strings.ToLower(string(v))
+				if !strings.HasPrefix(l, "http://") && !strings.HasPrefix(l, "https://") && !strings.HasPrefix(l, "mailto:") {
+					t.Fatalf("input %q produced href %q", in, v)
+				}
+			case "rel", "target", "class":
+			default:
+				t.Fatalf("input %q produced attribute %s: %s", in, k, out)
+			}
+		}
+	}
+}
+
+func FuzzToHTML(f *testing.F) {
+	for _, s := range []string{
+		"**a *b* c**", "[l](https://e.x)", "```\ncode", "> q\n>", "`x` _y_ \\*", "<img src=x onerror=alert(1)>",
+		"[a](https://x\" onmouseover=\"y)", "[[a](https://x)](https://y)", "***x***", "_a_b_",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out := ToHTML(in)
+		if !utf8.ValidString(in) {
+			return
+		}
+		checkSafe(t, in, out)
+	})
+}
+
+func FuzzClean(f *testing.F) {
+	for _, s := range []string{"", " a ", "a\r\nb", "\x00", strings.Repeat("x", MaxLength+1)} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out, err := Clean(in, true)
+		if err != nil {
+			return
+		}
+		if n := utf8.RuneCountInString(out); n > MaxLength {
+			t.Fatalf("Clean kept %d runes", n)
+		}
+		if again, err := Clean(out, true); err != nil || again != out {
+			t.Fatalf("Clean not idempotent: %q -> %q -> %q (%v)", in, out, again, err)
+		}
+	})
+}
@@ -0,0 +1,263 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// Node kinds. Blocks: paragraph, quote, code_block. Inlines: text, bold,
+// italic, code, link, break.
+const (
+	KindParagraph = "paragraph"
+	KindQuote     = "quote"
+	KindCodeBlock = "code_block"
+	KindText      = "text"
+	KindBold      = "bold"
+	KindItalic    = "italic"
+	KindCode      = "code"
+	KindLink      = "link"
+	KindBreak     = "break"
+)
+
+// Node is one element of the sanitized AST. Text holds literal text (text,
+// code, code_block), URL the vetted target of a link.
+type Node struct {
+	Kind     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Lang     string `json:"lang,omitempty"`
+	Children []Node `json:"children,omitempty"`
+}
+
+// maxNesting bounds bold/italic/link nesting; deeper markers stay text.
+const maxNesting = 8
+
+// Parse builds the AST of src. It never fails: anything outside the subset
+// is kept as text.
+func Parse(src string) []Node {
+	var (
+		blocks []Node
+		para   []string
+		quote  []string
+	)
+	flushPara := func() {
+		if len(para) > 0 {
+			blocks = append(blocks, Node{Kind: KindParagraph, Children: parseLines(para)})
+			para = nil
+		}
+	}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			blocks = append(blocks, Node{Kind: KindQuote, Children: parseLines(quote)})
+			quote = nil
+		}
+	}
+
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			flushPara()
+			flushQuote()
+			var code []string
+			j := i + 1
+			for ; j < len(lines) && !strings.HasPrefix(lines[j], "```"); j++ {
+				code = append(code, lines[j])
+			}
+			blocks = append(blocks, Node{Kind: KindCodeBlock, Lang: codeLang(line[3:]), Text: strings.Join(code, "\n")})
+			i = j // an unclosed fence runs to the end
+		case strings.HasPrefix(line, ">"):
+			flushPara()
+			quote = append(quote, strings.TrimPrefix(line[1:], " "))
+		case strings.TrimSpace(line) == "":
+			flushPara()
+			flushQuote()
+		default:
+			flushQuote()
+			para = append(para, line)
+		}
+	}
+	flushPara()
+	flushQuote()
+	return blocks
+}
+
+// codeLang keeps a short [A-Za-z0-9+#-] language hint, else none.
+func codeLang(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 20 {
+		return ""
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '+' || r == '#' || r == '-') {
+			return ""
+		}
+	}
+	return strings.ToLower(s)
+}
+
+func parseLines(lines []string) []Node {
+	var out []Node
+	for i, l := range lines {
+		if i > 0 {
+			out = append(out, Node{Kind: KindBreak})
+		}
+		out = append(out, parseInline([]rune(l), 0, false)...)
+	}
+	return out
+}
+
+const escapable = "\\`*_[]()>"
+
+// parseInline parses one line. Links do not nest: inside a link label,
+// brackets stay text.
+func parseInline(rs []rune, depth int, inLink bool) []Node {
+	var (
+		out []Node
+		buf strings.Builder
+	)
+	text := func() {
+		if buf.Len() > 0 {
+			out = append(out, Node{Kind: KindText, Text: buf.String()})
+			buf.Reset()
+		}
+	}
+	emit := func(n Node) {
+		text()
+		out = append(out, n)
+	}
+
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs) && strings.ContainsRune(escapable, rs[i+1]):
+			buf.WriteRune(rs[i+1])
+			i++
+			continue
+
+		case r == '`':
+			if j := indexRune(rs, '`', i+1); j > i+1 {
+				emit(Node{Kind: KindCode, Text: string(rs[i+1 : j])})
+				i = j
+				continue
+			}
+
+		case depth < maxNesting && r == '*' && i+1 < len(rs) && rs[i+1] == '*':
+			if j := indexPair(rs, '*', i+2); j > i+2 {
+				emit(Node{Kind: KindBold, Children: parseInline(rs[i+2:j], depth+1, inLink)})
+				i = j + 1
+				continue
+			}
+			// an unmatched ** is literal, not an italic opener
+			buf.WriteString("**")
+			i++
+			continue
+
+		case depth < maxNesting && (r == '*' || (r == '_' && !wordBefore(rs, i))):
+			if j := closeItalic(rs, r, i+1); j > i+1 {
+				emit(Node{Kind: KindItalic, Children: parseInline(rs[i+1:j], depth+1, inLink)})
+				i = j
+				continue
+			}
+
+		case depth < maxNesting && !inLink && r == '[':
+			if n, end, ok := parseLink(rs, i, depth); ok {
+				emit(n)
+				i = end
+				continue
+			}
+		}
+		buf.WriteRune(r)
+	}
+	text()
+	return out
+}
+
+func indexRune(rs []rune, r rune, from int) int {
+	for j := from; j < len(rs); j++ {
+		if rs[j] == r {
+			return j
+		}
+	}
+	return -1
+}
+
+// indexPair finds the next doubled r (e.g. the closing **).
+func indexPair(rs []rune, r rune, from int) int {
+	for j := from; j+1 < len(rs); j++ {
+		if rs[j] == '\\' {
+			j++
+			continue
+		}
+		if rs[j] == r && rs[j+1] == r {
+			return j
+		}
+	}
+	return -1
+}
+
+// closeItalic finds the closing single marker. A '*' that starts "**" is
+// skipped, and '_' must not sit inside a word (snake_case stays text).
+func closeItalic(rs []rune, r rune, from int) int {
+	for j := from; j < len(rs); j++ {
+		if rs[j] == '\\' {
+			j++
+			continue
+		}
+		if rs[j] != r {
+			continue
+		}
+		if r == '*' && j+1 < len(rs) && rs[j+1] == '*' {
+			j++
+			continue
+		}
+		if r == '_' && j+1 < len(rs) && isWord(rs[j+1]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func wordBefore(rs []rune, i int) bool { return i > 0 && isWord(rs[i-1]) }
+
+func isWord(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f
+}
+
+// parseLink reads [label](url) at rs[i]. Only http, https and mailto
+// targets become links; anything else stays text.
+func parseLink(rs []rune, i, depth int) (Node, int, bool) {
+	close := indexRune(rs, ']', i+1)
+	if close < 0 || close+1 >= len(rs) || rs[close+1] != '(' {
+		return Node{}, 0, false
+	}
+	end := indexRune(rs, ')', close+2)
+	if end < 0 {
+		return Node{}, 0, false
+	}
+	u, ok := safeURL(string(rs[close+2 : end]))
+	if !ok {
+		return Node{}, 0, false
+	}
+	label := rs[i+1 : close]
+	children := parseInline(label, depth+1, true)
+	if len(label) == 0 {
+		children = []Node{{Kind: KindText, Text: u}}
+	}
+	return Node{Kind: KindLink, URL: u, Children: children}, end, true
+}
+
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.ContainsAny(raw, " \t\n<>\"'") {
+		return "", false
+	}
+	lower := strings.ToLower(raw)
+	for _, scheme := range []string{"https://", "http://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) && len(raw) > len(scheme) {
+			return raw, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+// Package mention extracts @username, @here and @channel mentions from message content.
+package mention
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	KindUser    = "user"
+	KindHere    = "here"
+	KindChannel = "channel"
+)
+
+type Parsed struct {
+	Usernames []string // lower-cased, deduplicated, in order of appearance
+	Here      bool
+	Channel   bool
+}
+
+func (p Parsed) Empty() bool { return len(p.Usernames) == 0 && !p.Here && !p.Channel }
+
+// Minus returns the mentions in p that old does not have, such as the ones
+// an edit added.
+func (p Parsed) Minus(old Parsed) Parsed {
+	out := Parsed{Here: p.Here && !old.Here, Channel: p.Channel && !old.Channel}
+	for _, u := range p.Usernames {
+		if !slices.Contains(old.Usernames, u) {
+			out.Usernames = append(out.Usernames, u)
+		}
+	}
+	return out
+}
+
+// Parse finds mentions outside `code` spans. A mention must start the text or
+// follow a non-username character, so e-mail addresses are not mentions.
+// Usernames follow the registration rules (letter first, 3..32 of [A-Za-z0-9._-]).
+func Parse(content string) Parsed {
+	var (
+		p      Parsed
+		seen   = map[string]bool{}
+		inCode bool
+		prev   rune
+	)
+	rs := []rune(content)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r == '`' {
+			inCode = !inCode
+		}
+		if r != '@' || inCode || (i > 0 && isNameRune(prev)) {
+			prev = r
+			continue
+		}
+
+		j := i + 1
+		for j < len(rs) && isNameRune(rs[j]) {
+			j++
+		}
+		name := strings.TrimRight(string(rs[i+1:j]), ".-_") // "@bob." ends a sentence
+		prev = r
+		if name == "" {
+			continue
+		}
+		i += len([]rune(name))
+		prev = rs[i]
+
+		lower := strings.ToLower(name)
+		switch {
+		case lower == KindHere:
+			p.Here = true
+		case lower == KindChannel:
+			p.Channel = true
+		case validName(name) && !seen[lower]:
+			seen[lower] = true
+			p.Usernames = append(p.Usernames, lower)
+		}
+	}
+	return p
+}
+
+func isNameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '.' || r == '_' || r == '-'
+}
+
+func validName(s string) bool {
+	if len(s) < 3 || len(s) > 32 {
+		return false
+	}
+	c := s[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
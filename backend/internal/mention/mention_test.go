@@ -0,0 +1,39 @@
+package mention
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Parsed
+	}{
+		{"hi @alice and @Bob.", Parsed{Usernames: []string{"alice", "bob"}}},
+		{"@alice @alice @ALICE", Parsed{Usernames: []string{"alice"}}},
+		{"mail me at bob@example.com", Parsed{}},
+		{"@here deploy is done, @channel FYI", Parsed{Here: true, Channel: true}},
+		{"`@alice` is code, @carol is not", Parsed{Usernames: []string{"carol"}}},
+		{"@ab too short, @1abc bad start", Parsed{}},
+		{"(@dave_x)", Parsed{Usernames: []string{"dave_x"}}},
+		{"@", Parsed{}},
+	}
+	for _, c := range cases {
+		if got := Parse(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("Parse(%q) = %+v, want %+v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestMinus(t *testing.T) {
+	old := Parse("@alice @here ship it")
+	got := Parse("@alice @bob @here @channel ship it").Minus(old)
+	want := Parsed{Usernames: []string{"bob"}, Channel: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Minus = %+v, want %+v", got, want)
+	}
+	if !Parse("@alice typo fixed").Minus(Parse("@alice typo fxied")).Empty() {
+		t.Fatal("unchanged mentions notified again")
+	}
+}
@@ -3,26 +3,286 @@ package models
 import "time"
 
 type Room struct {
-	ID       int64  `json:"id"`
-	Name     string `json:"name"`
-	IsPublic bool   `json:"is_public"`
+	ID           int64  `json:"id"`
+	WorkspaceID  *int64 `json:"workspace_id,omitempty"`
+	Name         string `json:"name"`
+	IsPublic     bool   `json:"is_public"`
+	UnreadCount  int    `json:"unread_count"` // capped, see store.MaxUnreadCount
+	MentionCount int    `json:"mention_count"`
 }
 
-type Message struct {
-	ID        int64     `json:"id"`
+// ReadMarker is a user's last-read position in a room.
+type ReadMarker struct {
 	RoomID    int64     `json:"room_id"`
 	UserID    int64     `json:"user_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	MessageID int64     `json:"message_id"`
+	ReadAt    time.Time `json:"read_at"`
+}
+
+type Message struct {
+	ID          int64        `json:"id"`
+	RoomID      int64        `json:"room_id"`
+	UserID      int64        `json:"user_id"`
+	ParentID    *int64       `json:"parent_id,omitempty"`   // thread root
+	ReplyToID   *int64       `json:"reply_to_id,omitempty"` // quoted message
+	ReplyTo     *MessageRef  `json:"reply_to,omitempty"`
+	Content     string       `json:"content"`
+	HTML        string       `json:"html,omitempty"` // Content rendered from the markdown subset, safe to insert
+	CreatedAt   time.Time    `json:"created_at"`
+	EditedAt    *time.Time   `json:"edited_at,omitempty"`
+	Deleted     bool         `json:"deleted,omitempty"` // tombstone, content stripped
+	Reactions   []Reaction   `json:"reactions,omitempty"`
+	ReplyCount  int          `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time   `json:"last_reply_at,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Embeds      []Embed      `json:"embeds,omitempty"` // link previews, filled in asynchronously
+	Poll        *Poll        `json:"poll,omitempty"`   // set on poll messages; Content is the question
+	// ExpiresAt marks an ephemeral message, deleted for everyone at that
+	// time. Ephemeral messages are left out of search and exports.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Poll holds a poll message's options and live tallies. Voters are listed
+// only when the poll isn't anonymous.
+type Poll struct {
+	MessageID   int64        `json:"message_id"`
+	Multiple    bool         `json:"multiple"`
+	Anonymous   bool         `json:"anonymous"`
+	ClosesAt    *time.Time   `json:"closes_at,omitempty"`
+	Closed      bool         `json:"closed"`
+	TotalVoters int          `json:"total_voters"`
+	Options     []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	ID     int64   `json:"id"`
+	Text   string  `json:"text"`
+	Votes  int     `json:"votes"`
+	Voters []int64 `json:"voters,omitempty"`
+	Me     bool    `json:"me"` // viewer voted for this option
+}
+
+// Shared returns the poll without the viewer's own choices, for room-wide events.
+func (p Poll) Shared() Poll {
+	p.Options = append([]PollOption(nil), p.Options...)
+	for i := range p.Options {
+		p.Options[i].Me = false
+	}
+	return p
 }
 
+// Embed is a link preview built from a page's OpenGraph or oEmbed metadata.
+type Embed struct {
+	URL         string `json:"url"`
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+}
+
+// Attachment is an uploaded file. Bytes are served from
+// /rooms/:id/attachments/:attId and, for images, .../thumbnail.
+type Attachment struct {
+	ID           int64     `json:"id"`
+	RoomID       int64     `json:"room_id"`
+	MessageID    *int64    `json:"message_id,omitempty"` // nil until sent with a message
+	UploaderID   int64     `json:"uploader_id"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	StorageKey string  `json:"-"`
+	ThumbKey   *string `json:"-"`
+}
+
+// MessageRef is a compact snapshot of a quoted message.
+type MessageRef struct {
+	ID       int64  `json:"id"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Excerpt  string `json:"excerpt"`
+	Deleted  bool   `json:"deleted"`
+}
+
+// ThreadSummary is the preview state of a thread root.
+type ThreadSummary struct {
+	RoomID      int64      `json:"room_id"`
+	MessageID   int64      `json:"message_id"`
+	ReplyCount  int        `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+}
+
+// Reaction is the aggregate of one emoji on a message.
+type Reaction struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	Me    bool   `json:"me"` // viewer reacted with this emoji
+}
+
+// PageInfo carries opaque cursors for both directions: OlderCursor loads
+// older messages (HasMore), NewerCursor newer ones (HasNewer).
 type PageInfo struct {
-	NextBeforeTS *time.Time `json:"next_before_ts,omitempty"`
-	NextBeforeID *int64     `json:"next_before_id,omitempty"`
-	HasMore      bool       `json:"has_more"`
+	OlderCursor string `json:"older_cursor,omitempty"`
+	HasMore     bool   `json:"has_more"`
+	NewerCursor string `json:"newer_cursor,omitempty"`
+	HasNewer    bool   `json:"has_newer"`
 }
 
 type MessageResp struct {
 	Items    []Message `json:"items"`
 	PageInfo PageInfo  `json:"page_info"`
 }
+
+// Pin is a message pinned to its room by a moderator.
+type Pin struct {
+	Message          Message   `json:"message"`
+	PinnedBy         *int64    `json:"pinned_by,omitempty"` // nil once the user is deleted
+	PinnedByUsername string    `json:"pinned_by_username,omitempty"`
+	PinnedAt         time.Time `json:"pinned_at"`
+}
+
+// Bookmark is a message the user saved, optionally with a reminder.
+type Bookmark struct {
+	UserID     int64      `json:"user_id"`
+	Message    Message    `json:"message"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RemindAt   *time.Time `json:"remind_at,omitempty"`
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+}
+
+type BookmarkResp struct {
+	Items    []Bookmark `json:"items"`
+	PageInfo PageInfo   `json:"page_info"`
+}
+
+const (
+	ScheduledPending = "pending"
+	ScheduledSent    = "sent"
+	ScheduledFailed  = "failed"
+)
+
+// ScheduledMessage is a message to be posted by the dispatcher at SendAt.
+type ScheduledMessage struct {
+	ID        int64     `json:"id"`
+	RoomID    int64     `json:"room_id"`
+	UserID    int64     `json:"user_id"`
+	Content   string    `json:"content"`
+	ParentID  *int64    `json:"parent_id,omitempty"`
+	ReplyToID *int64    `json:"reply_to_id,omitempty"`
+	SendAt    time.Time `json:"send_at"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`      // set when failed
+	MessageID *int64    `json:"message_id,omitempty"` // set once sent
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SearchHit is a message matching a search, with a highlighted snippet
+// (HTML-escaped, matches wrapped in <mark>).
+type SearchHit struct {
+	Message  Message `json:"message"`
+	RoomName string  `json:"room_name"`
+	Snippet  string  `json:"snippet"`
+}
+
+type SearchResp struct {
+	Items    []SearchHit `json:"items"`
+	PageInfo PageInfo    `json:"page_info"`
+}
+
+// Conversation is a DM or group DM, backed by a hidden private room.
+type Conversation struct {
+	RoomID         int64      `json:"room_id"`
+	ParticipantIDs []int64    `json:"participant_ids"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// CanModerate reports whether a room role may act on other users' messages.
+func CanModerate(role string) bool {
+	return role == RoleOwner || role == RoleAdmin || role == RoleModerator
+}
+
+type Workspace struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role,omitempty"` // caller's role
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Invite targets exactly one of WorkspaceID or RoomID.
+type Invite struct {
+	ID          int64      `json:"id"`
+	Code        string     `json:"code"`
+	WorkspaceID *int64     `json:"workspace_id,omitempty"`
+	RoomID      *int64     `json:"room_id,omitempty"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedBy   int64      `json:"created_by"`
+	MaxUses     *int       `json:"max_uses,omitempty"`
+	UseCount    int        `json:"use_count"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type InviteRedemption struct {
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// InviteResult is what accepting an invite joined the caller to.
+type InviteResult struct {
+	Workspace *Workspace `json:"workspace,omitempty"`
+	Room      *Room      `json:"room,omitempty"`
+}
+
+// Receipt says a user has read up to (at least) a message.
+type Receipt struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	ReadAt   time.Time `json:"read_at"`
+}
+
+// UserSettings are per-user preferences.
+type UserSettings struct {
+	ShareReadReceipts bool `json:"share_read_receipts"`
+}
+
+// Presence is a user's effective status: online, away, dnd or offline.
+type Presence struct {
+	UserID int64  `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// Mention notifies UserID that AuthorID mentioned them in MessageID.
+type Mention struct {
+	ID        int64      `json:"id"`
+	MessageID int64      `json:"message_id"`
+	RoomID    int64      `json:"room_id"`
+	UserID    int64      `json:"user_id"`
+	AuthorID  int64      `json:"author_id"`
+	Kind      string     `json:"kind"` // user | here | channel
+	Excerpt   string     `json:"excerpt"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
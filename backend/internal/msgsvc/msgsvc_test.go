@@ -0,0 +1,326 @@
+package msgsvc
+
+import (
+	"backend/internal/mention"
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreate_RunsChainInOrderAndStopsOnError(t *testing.T) {
+	var ran []string
+	step := func(name string, err error) Processor {
+		return ProcessorFunc(func(_ context.Context, d *Draft) error {
+			ran = append(ran, name)
+			if err == nil && name == "persist" {
+				d.Message = models.Message{ID: 7, Content: d.Content}
+			}
+			return err
+		})
+	}
+
+	s := New([]Processor{Normalize(), step("a", nil)}, step("persist", nil), step("post", nil))
+	m, err := s.Create(context.Background(), Input{RoomID: 1, UserID: 2, Content: "  hi  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 7 || m.Content != "hi" {
+		t.Fatalf("got %+v", m)
+	}
+	if strings.Join(ran, ",") != "a,persist,post" {
+		t.Fatalf("ran %v", ran)
+	}
+
+	ran = nil
+	boom := errors.New("boom")
+	s = New([]Processor{step("a", boom)}, step("persist", nil))
+	if _, err := s.Create(context.Background(), Input{}); !errors.Is(err, boom) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(ran) != 1 {
+		t.Fatalf("chain continued after error: %v", ran)
+	}
+}
+
+func TestPublish_RunsOnlyThePublishStage(t *testing.T) {
+	var ran []string
+	var mentions bool
+	step := func(name string) Processor {
+		return ProcessorFunc(func(_ context.Context, d *Draft) error {
+			ran = append(ran, name)
+			mentions = !d.Mentions.Empty()
+			return nil
+		})
+	}
+	s := New([]Processor{step("pre")}, step("persist"), step("post"))
+	if err := s.Publish(context.Background(), models.Message{ID: 3, RoomID: 1, Content: "hi @here"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ran, ",") != "post" || !mentions {
+		t.Fatalf("ran %v, mentions %v", ran, mentions)
+	}
+}
+
+func TestValidateAndNormalize(t *testing.T) {
+	id := func(v int64) *int64 { return &v }
+	cases := []struct {
+		name string
+		in   Input
+		msg  string
+	}{
+		{"empty", Input{RoomID: 1, Content: "   "}, "content length 1..2000"},
+		{"too long", Input{RoomID: 1, Content: strings.Repeat("x", 2001)}, "content length 1..2000"},
+		{"bad room", Input{Content: "hi"}, "invalid room id"},
+		{"bad parent", Input{RoomID: 1, Content: "hi", ParentID: id(0)}, "invalid parent_id or reply_to_id"},
+		{"ttl too short", Input{RoomID: 1, Content: "hi", TTLSeconds: 5}, "ttl_seconds must be between 10 and 604800"},
+		{"ttl negative", Input{RoomID: 1, Content: "hi", TTLSeconds: -1}, "ttl_seconds must be between 10 and 604800"},
+		{"too many attachments", Input{RoomID: 1, AttachmentIDs: []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}}, "too many attachments"},
+	}
+	s := New([]Processor{Validate(), Normalize()}, nil)
+	for _, tc := range cases {
+		_, err := s.Create(context.Background(), tc.in)
+		var e *Error
+		if !errors.As(err, &e) || e.Code != CodeValidation || e.Msg != tc.msg {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+
+	// Attachments alone are a valid message; duplicate ids collapse.
+	var got []int64
+	s = New([]Processor{Validate(), Normalize()}, ProcessorFunc(func(_ context.Context, d *Draft) error {
+		got = d.AttachmentIDs
+		return nil
+	}))
+	if _, err := s.Create(context.Background(), Input{RoomID: 1, AttachmentIDs: []int64{3, 1, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("attachment ids = %v", got)
+	}
+}
+
+func TestValidatePoll(t *testing.T) {
+	poll := func(opts ...string) *store.NewPoll { return &store.NewPoll{Options: opts} }
+	past := time.Now().Add(-time.Minute)
+	cases := []struct {
+		name string
+		p    *store.NewPoll
+		msg  string
+	}{
+		{"one option", poll("yes"), "a poll needs 2..10 options"},
+		{"blank option", poll("yes", "  "), "poll options must be 1..200 characters"},
+		{"duplicate", poll("Yes", " yes"), "poll options must be distinct"},
+		{"closed already", &store.NewPoll{Options: []string{"a", "b"}, ClosesAt: &past}, "closes_at must be within the next year"},
+	}
+	s := New([]Processor{ValidatePoll()}, nil)
+	for _, tc := range cases {
+		_, err := s.Create(context.Background(), Input{RoomID: 1, Content: "lunch?", Poll: tc.p})
+		var e *Error
+		if !errors.As(err, &e) || e.Msg != tc.msg {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+	}
+
+	var got []string
+	s = New([]Processor{ValidatePoll()}, ProcessorFunc(func(_ context.Context, d *Draft) error {
+		got = d.Poll.Options
+		return nil
+	}))
+	if _, err := s.Create(context.Background(), Input{RoomID: 1, Content: "lunch?", Poll: poll(" pizza ", "sushi")}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "|") != "pizza|sushi" {
+		t.Fatalf("options = %q", got)
+	}
+
+	// a poll needs a question even with attachments
+	_, err := New([]Processor{Normalize()}, nil).Create(context.Background(), Input{RoomID: 1, AttachmentIDs: []int64{1}, Poll: poll("a", "b")})
+	if e := (*Error)(nil); !errors.As(err, &e) || e.Msg != "content length 1..2000" {
+		t.Fatalf("empty question: err = %v", err)
+	}
+}
+
+func TestSpamGuard(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := &SpamGuard{Rate: 1, Burst: 3, MaxRepeats: 2, RepeatWindow: time.Minute, now: func() time.Time { return now }}
+	send := func(user int64, content string) error {
+		return g.Process(context.Background(), &Draft{Input: Input{UserID: user, Content: content}})
+	}
+	limited := func(err error) bool {
+		var e *Error
+		return errors.As(err, &e) && e.Code == CodeRateLimited
+	}
+
+	for i, c := range []string{"a", "b", "c"} {
+		if err := send(1, c); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if err := send(1, "d"); !limited(err) {
+		t.Fatalf("burst exceeded: err = %v", err)
+	}
+	if err := send(2, "a"); err != nil {
+		t.Fatalf("other user limited: %v", err)
+	}
+
+	now = now.Add(10 * time.Second) // bucket refilled
+	if err := send(1, "same"); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(1, "same"); err != nil {
+		t.Fatal(err)
+	}
+	if err := send(1, "same"); !limited(err) {
+		t.Fatalf("third repeat: err = %v", err)
+	}
+	if err := send(1, "different"); err != nil {
+		t.Fatalf("new content after repeats: %v", err)
+	}
+}
+
+func TestEditAndDelete_GoThroughTheChain(t *testing.T) {
+	var ran []string
+	step := func(name string) Processor {
+		return ProcessorFunc(func(_ context.Context, d *Draft) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+	var got Draft
+	persist := ProcessorFunc(func(_ context.Context, d *Draft) error {
+		got = *d
+		d.Message = models.Message{ID: d.MessageID, Content: d.Content}
+		return nil
+	})
+	s := New([]Processor{Normalize(), step("prepare")}, persist, step("publish"))
+
+	m, err := s.Edit(context.Background(), 1, 9, 2, "  fixed  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Op != OpEdit || got.MessageID != 9 || m.Content != "fixed" {
+		t.Fatalf("edit: draft %+v, message %+v", got, m)
+	}
+	if _, err := New([]Processor{Normalize()}, persist).Edit(context.Background(), 1, 9, 2, "   "); err == nil {
+		t.Fatal("blank edit accepted")
+	}
+
+	ran = nil
+	if _, err := s.Delete(context.Background(), 1, 9, 2); err != nil {
+		t.Fatal(err)
+	}
+	// nothing to prepare on a delete
+	if got.Op != OpDelete || strings.Join(ran, ",") != "publish" {
+		t.Fatalf("delete: op %v, ran %v", got.Op, ran)
+	}
+}
+
+type fakeHub struct {
+	Broadcaster
+	events    []string
+	mentioned []models.Mention
+}
+
+func (h *fakeHub) NotifyMentions(ms []models.Mention)           { h.mentioned = append(h.mentioned, ms...) }
+func (h *fakeHub) OnlineUserIDs(context.Context, int64) []int64 { return nil }
+
+func (h *fakeHub) BroadcastMessage(models.Message)        { h.events = append(h.events, "create") }
+func (h *fakeHub) BroadcastMessageUpdated(models.Message) { h.events = append(h.events, "update") }
+func (h *fakeHub) BroadcastMessageDeleted(models.Message) { h.events = append(h.events, "delete") }
+func (h *fakeHub) TypingStop(int64, int64)                {}
+
+func TestBroadcast_PerOp(t *testing.T) {
+	hub := &fakeHub{}
+	b := Broadcast(nil, hub, nil)
+	for _, op := range []Op{OpCreate, OpEdit, OpDelete} {
+		if err := b.Process(context.Background(), &Draft{Op: op, Message: models.Message{ID: 3, RoomID: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(hub.events, ",") != "create,update,delete" {
+		t.Fatalf("events %v", hub.events)
+	}
+}
+
+func TestValidate_RunsOnlyThePrepareStage(t *testing.T) {
+	stored := false
+	persist := ProcessorFunc(func(context.Context, *Draft) error {
+		stored = true
+		return nil
+	})
+	s := New([]Processor{Validate(), Normalize()}, persist)
+
+	in, err := s.Validate(context.Background(), Input{RoomID: 1, Content: "  later  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Content != "later" || stored {
+		t.Fatalf("content %q, stored %v", in.Content, stored)
+	}
+	if _, err := s.Validate(context.Background(), Input{RoomID: 1, Content: " "}); err == nil {
+		t.Fatal("blank content accepted")
+	}
+}
+
+func TestSpamGuard_IgnoresEdits(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := &SpamGuard{Rate: 1, Burst: 1, MaxRepeats: 1, RepeatWindow: time.Minute, now: func() time.Time { return now }}
+	if err := g.Process(context.Background(), &Draft{Input: Input{UserID: 1, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	// the bucket is empty and the content repeats, yet typo fixes go through
+	for i := 0; i < 3; i++ {
+		if err := g.Process(context.Background(), &Draft{Input: Input{UserID: 1, Content: "hi"}, Op: OpEdit}); err != nil {
+			t.Fatalf("edit %d: %v", i, err)
+		}
+	}
+}
+
+func TestBroadcast_EditNotifiesOnlyAddedMentions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	st := store.NewFormDB(db)
+
+	// @alice was in the original: only @bob is looked up and stored
+	mock.ExpectQuery(`SELECT id FROM users WHERE LOWER\(username\) = ANY\(\$1\)`).
+		WithArgs(`{"bob"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(`INSERT INTO mentions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "created_at"}).AddRow(1, 9, "user", time.Now()))
+
+	hub := &fakeHub{}
+	d := &Draft{
+		Op:       OpEdit,
+		Mentions: mention.Parse("@alice @bob review please"),
+		Previous: "@alice review plz",
+		Message:  models.Message{ID: 3, RoomID: 1, UserID: 7, Content: "@alice @bob review please"},
+	}
+	if err := Broadcast(st, hub, nil).Process(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if len(hub.mentioned) != 1 || hub.mentioned[0].UserID != 9 {
+		t.Fatalf("notified %+v", hub.mentioned)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+
+	// an edit that adds no mention touches nothing
+	hub.mentioned = nil
+	d.Previous = d.Message.Content
+	if err := Broadcast(st, hub, nil).Process(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	if len(hub.mentioned) != 0 {
+		t.Fatalf("notified %+v", hub.mentioned)
+	}
+}
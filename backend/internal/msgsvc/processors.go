@@ -0,0 +1,242 @@
+package msgsvc
+
+import (
+	"backend/internal/markdown"
+	"backend/internal/mention"
+	"backend/internal/models"
+	"backend/internal/store"
+	"backend/internal/unfurl"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Normalize cleans the content (markdown.Clean) and deduplicates
+// attachment ids. Content may be empty only on a message with attachments
+// that isn't a poll.
+func Normalize() Processor {
+	return ProcessorFunc(func(_ context.Context, d *Draft) error {
+		if len(d.AttachmentIDs) > 0 {
+			d.AttachmentIDs = slices.Compact(slices.Sorted(slices.Values(d.AttachmentIDs)))
+		}
+		content, err := markdown.Clean(d.Content, len(d.AttachmentIDs) > 0 && d.Poll == nil)
+		if err != nil {
+			return invalid("content length 1..2000")
+		}
+		d.Content = content
+		return nil
+	})
+}
+
+// Bounds of an ephemeral message's lifetime.
+const (
+	MinTTLSeconds = 10
+	MaxTTLSeconds = 7 * 24 * 3600
+)
+
+// Validate checks the shape of the input; references are checked on insert.
+func Validate() Processor {
+	return ProcessorFunc(func(_ context.Context, d *Draft) error {
+		if d.RoomID <= 0 {
+			return invalid("invalid room id")
+		}
+		if (d.ParentID != nil && *d.ParentID <= 0) || (d.ReplyToID != nil && *d.ReplyToID <= 0) {
+			return invalid("invalid parent_id or reply_to_id")
+		}
+		if len(d.AttachmentIDs) > store.MaxAttachmentsPerMessage {
+			return invalid("too many attachments")
+		}
+		if d.TTLSeconds != 0 && (d.TTLSeconds < MinTTLSeconds || d.TTLSeconds > MaxTTLSeconds) {
+			return invalid("ttl_seconds must be between 10 and 604800")
+		}
+		return nil
+	})
+}
+
+// Poll limits.
+const (
+	MinPollOptions    = 2
+	MaxPollOptions    = 10
+	MaxPollOptionLen  = 200 // runes
+	MaxPollOpenPeriod = 365 * 24 * time.Hour
+)
+
+// ValidatePoll trims and checks a poll's options and close time. It runs
+// after Normalize, which has already required a question.
+func ValidatePoll() Processor {
+	return ProcessorFunc(func(_ context.Context, d *Draft) error {
+		if d.Poll == nil {
+			return nil
+		}
+		p := *d.Poll
+		if len(p.Options) < MinPollOptions || len(p.Options) > MaxPollOptions {
+			return invalid("a poll needs 2..10 options")
+		}
+		seen := make(map[string]bool, len(p.Options))
+		opts := make([]string, len(p.Options))
+		for i, o := range p.Options {
+			o = strings.TrimSpace(o)
+			if o == "" || !utf8.ValidString(o) || utf8.RuneCountInString(o) > MaxPollOptionLen {
+				return invalid("poll options must be 1..200 characters")
+			}
+			if key := strings.ToLower(o); seen[key] {
+				return invalid("poll options must be distinct")
+			} else {
+				seen[key] = true
+			}
+			opts[i] = o
+		}
+		p.Options = opts
+		if p.ClosesAt != nil {
+			if until := time.Until(*p.ClosesAt); until <= 0 || until > MaxPollOpenPeriod {
+				return invalid("closes_at must be within the next year")
+			}
+		}
+		d.Poll = &p
+		return nil
+	})
+}
+
+// CheckAccess rejects rooms that do not exist or that the author cannot
+// see. It runs per message, so revoked access applies to open sockets too.
+func CheckAccess(st *store.Store) Processor {
+	return ProcessorFunc(func(ctx context.Context, d *Draft) error {
+		ok, err := st.CanAccessRoom(ctx, d.RoomID, d.UserID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &Error{Code: CodeNotFound, Msg: "room not found"}
+		}
+		return nil
+	})
+}
+
+// ExtractMentions parses @mentions for the processors after Persist.
+func ExtractMentions() Processor {
+	return ProcessorFunc(func(_ context.Context, d *Draft) error {
+		d.Mentions = mention.Parse(d.Content)
+		return nil
+	})
+}
+
+// Persist stores the draft per its Op and maps store rejections to *Error.
+func Persist(st *store.Store) Processor {
+	return ProcessorFunc(func(ctx context.Context, d *Draft) error {
+		var (
+			m   models.Message
+			err error
+		)
+		switch d.Op {
+		case OpEdit:
+			m, d.Previous, err = st.EditMessage(ctx, d.RoomID, d.MessageID, d.UserID, d.Content)
+		case OpDelete:
+			m, err = st.DeleteMessage(ctx, d.RoomID, d.MessageID, d.UserID)
+		default:
+			m, err = st.CreateMessage(ctx, store.NewMessage{
+				RoomID: d.RoomID, UserID: d.UserID, Content: d.Content,
+				ParentID: d.ParentID, ReplyToID: d.ReplyToID,
+				AttachmentIDs: d.AttachmentIDs,
+				TTL:           time.Duration(d.TTLSeconds) * time.Second,
+				Poll:          d.Poll,
+			})
+		}
+		switch {
+		case errors.Is(err, store.ErrNotFound) && d.Op != OpCreate:
+			return &Error{Code: CodeNotFound, Msg: "message not found"}
+		case errors.Is(err, store.ErrNotFound):
+			return &Error{Code: CodeNotFound, Msg: "room not found"}
+		case errors.Is(err, store.ErrForbidden) && d.Op == OpEdit:
+			return &Error{Code: CodeForbidden, Msg: "only the author or a moderator can edit"}
+		case errors.Is(err, store.ErrForbidden):
+			return &Error{Code: CodeForbidden, Msg: "only the author or a moderator can delete"}
+		case errors.Is(err, store.ErrEditWindowClosed):
+			return &Error{Code: CodeEditWindow, Msg: "edit window closed"}
+		case errors.Is(err, store.ErrInvalidParent):
+			return invalid("parent_id must be a root message of this room")
+		case errors.Is(err, store.ErrInvalidReplyTo):
+			return invalid("reply_to_id must be a message of this room")
+		case errors.Is(err, store.ErrInvalidAttachment):
+			return invalid("attachment_ids must be your unsent uploads in this room")
+		case err != nil:
+			return err
+		}
+		d.Message = m
+		return nil
+	})
+}
+
+// Broadcaster is the part of ws.Hub the chain fans out through.
+type Broadcaster interface {
+	BroadcastMessage(m models.Message)
+	BroadcastMessageUpdated(m models.Message)
+	BroadcastMessageDeleted(m models.Message)
+	BroadcastThreadUpdated(t models.ThreadSummary)
+	NotifyMentions(ms []models.Mention)
+	OnlineUserIDs(ctx context.Context, roomID int64) []int64
+	TypingStop(roomID, userID int64)
+}
+
+// Broadcast publishes the stored message: message.create, .update or
+// .delete, the thread preview, mention.created, and the link-preview job.
+// The message is already saved, so failures here are logged rather than
+// returned.
+func Broadcast(st *store.Store, hub Broadcaster, unfurler *unfurl.Worker) Processor {
+	return ProcessorFunc(func(ctx context.Context, d *Draft) error {
+		m := d.Message
+		if hub != nil {
+			switch d.Op {
+			case OpEdit:
+				hub.BroadcastMessageUpdated(m)
+			case OpDelete:
+				hub.BroadcastMessageDeleted(m)
+			default:
+				hub.TypingStop(m.RoomID, m.UserID)
+				hub.BroadcastMessage(m)
+			}
+			// an edit leaves the reply count and last reply as they were
+			if m.ParentID != nil && d.Op != OpEdit {
+				if sum, err := st.ThreadSummary(ctx, m.RoomID, *m.ParentID); err != nil {
+					log.Printf("[msgsvc] thread summary root_id=%d: %v", *m.ParentID, err)
+				} else {
+					hub.BroadcastThreadUpdated(sum)
+				}
+			}
+		}
+		if d.Op == OpDelete {
+			return nil
+		}
+		mentions := d.Mentions
+		if d.Op == OpEdit {
+			// whoever the old content mentioned has been notified already
+			mentions = mentions.Minus(mention.Parse(d.Previous))
+		}
+		if !mentions.Empty() {
+			var online []int64
+			if hub != nil {
+				online = hub.OnlineUserIDs(ctx, m.RoomID)
+			}
+			if ms, err := st.RecordMentions(ctx, m, mentions, online); err != nil {
+				log.Printf("[msgsvc] mentions msg_id=%d: %v", m.ID, err)
+			} else if hub != nil {
+				hub.NotifyMentions(ms)
+			}
+		}
+		unfurler.Enqueue(m)
+		return nil
+	})
+}
+
+// Default is the standard chain. A nil spam guard skips spam checks.
+func Default(st *store.Store, hub Broadcaster, unfurler *unfurl.Worker, spam *SpamGuard) *Service {
+	ps := []Processor{Validate(), Normalize(), ValidatePoll(), CheckAccess(st)}
+	if spam != nil {
+		ps = append(ps, spam)
+	}
+	ps = append(ps, ExtractMentions())
+	return New(ps, Persist(st), Broadcast(st, hub, unfurler))
+}
@@ -0,0 +1,160 @@
+// Package msgsvc is the single path for creating, editing and deleting
+// messages. REST and WebSocket both call the Service, which runs an ordered
+// chain of processors, so a rule added to the chain applies to every
+// transport.
+package msgsvc
+
+import (
+	"backend/internal/mention"
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+)
+
+// Input is a message as submitted by a client.
+type Input struct {
+	RoomID        int64
+	UserID        int64
+	Content       string
+	ParentID      *int64
+	ReplyToID     *int64
+	AttachmentIDs []int64
+	TTLSeconds    int            // ephemeral when > 0
+	Poll          *store.NewPoll // makes the message a poll; Content is the question
+}
+
+// Op is what the chain does with a draft.
+type Op int
+
+const (
+	OpCreate Op = iota
+	OpEdit
+	OpDelete
+)
+
+// Draft is the state passed along the chain. Prepare processors refine
+// Input; Persist fills Message; Publish processors act on it. MessageID is
+// the target of an edit or delete, Previous the content an edit replaced.
+type Draft struct {
+	Input
+	Op        Op
+	MessageID int64
+	Mentions  mention.Parsed
+	Message   models.Message
+	Previous  string
+}
+
+type Processor interface {
+	Process(ctx context.Context, d *Draft) error
+}
+
+// ProcessorFunc adapts a function to Processor.
+type ProcessorFunc func(ctx context.Context, d *Draft) error
+
+func (f ProcessorFunc) Process(ctx context.Context, d *Draft) error { return f(ctx, d) }
+
+// Service runs a message through its processors in three stages: Prepare
+// checks and rewrites the input, Persist stores it, and Publish fans the
+// stored message out.
+type Service struct {
+	prepare []Processor
+	persist Processor
+	publish []Processor
+}
+
+// New builds a Service; the first error stops the chain.
+func New(prepare []Processor, persist Processor, publish ...Processor) *Service {
+	return &Service{prepare: prepare, persist: persist, publish: publish}
+}
+
+// Create runs the chain and returns the stored message. Rejections are
+// *Error values; anything else is an internal failure.
+func (s *Service) Create(ctx context.Context, in Input) (models.Message, error) {
+	return s.run(ctx, &Draft{Input: in}, s.prepare)
+}
+
+// Edit replaces the content of a message in the room, as its author or a
+// moderator, through the whole chain.
+func (s *Service) Edit(ctx context.Context, roomID, msgID, userID int64, content string) (models.Message, error) {
+	return s.run(ctx, &Draft{
+		Input: Input{RoomID: roomID, UserID: userID, Content: content},
+		Op:    OpEdit, MessageID: msgID,
+	}, s.prepare)
+}
+
+// Delete soft-deletes a message in the room and returns its tombstone.
+// There is no content to prepare, so it starts at Persist.
+func (s *Service) Delete(ctx context.Context, roomID, msgID, userID int64) (models.Message, error) {
+	return s.run(ctx, &Draft{
+		Input: Input{RoomID: roomID, UserID: userID},
+		Op:    OpDelete, MessageID: msgID,
+	}, nil)
+}
+
+func (s *Service) run(ctx context.Context, d *Draft, prepare []Processor) (models.Message, error) {
+	for _, p := range prepare {
+		if err := p.Process(ctx, d); err != nil {
+			return models.Message{}, err
+		}
+	}
+	if err := s.persist.Process(ctx, d); err != nil {
+		return models.Message{}, err
+	}
+	if err := s.runPublish(ctx, d); err != nil {
+		return models.Message{}, err
+	}
+	return d.Message, nil
+}
+
+// Validate runs only the prepare stage and returns the input as it would be
+// stored, for messages stored outside the chain such as scheduled ones.
+func (s *Service) Validate(ctx context.Context, in Input) (Input, error) {
+	d := &Draft{Input: in}
+	for _, p := range s.prepare {
+		if err := p.Process(ctx, d); err != nil {
+			return Input{}, err
+		}
+	}
+	return d.Input, nil
+}
+
+// Publish runs only the publish stage, for a message stored outside the
+// chain such as a scheduled message posted by the dispatcher.
+func (s *Service) Publish(ctx context.Context, m models.Message) error {
+	return s.runPublish(ctx, &Draft{
+		Input: Input{
+			RoomID: m.RoomID, UserID: m.UserID, Content: m.Content,
+			ParentID: m.ParentID, ReplyToID: m.ReplyToID,
+		},
+		Mentions: mention.Parse(m.Content),
+		Message:  m,
+	})
+}
+
+func (s *Service) runPublish(ctx context.Context, d *Draft) error {
+	for _, p := range s.publish {
+		if err := p.Process(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Error codes, shared with the REST error JSON.
+const (
+	CodeValidation  = "VALIDATION_FAILED"
+	CodeNotFound    = "NOT_FOUND"
+	CodeRateLimited = "RATE_LIMITED"
+	CodeForbidden   = "FORBIDDEN"
+	CodeEditWindow  = "EDIT_WINDOW_CLOSED"
+)
+
+// Error is a rejection the client can act on.
+type Error struct {
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string { return e.Msg }
+
+func invalid(msg string) *Error { return &Error{Code: CodeValidation, Msg: msg} }
@@ -0,0 +1,87 @@
+package msgsvc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpamGuard limits each user to a token bucket of messages and rejects the
+// same content sent more than MaxRepeats times in a row within RepeatWindow.
+// State is per instance.
+type SpamGuard struct {
+	Rate         float64 // tokens per second
+	Burst        float64
+	MaxRepeats   int
+	RepeatWindow time.Duration
+
+	mu    sync.Mutex
+	users map[int64]*spamState
+	now   func() time.Time
+}
+
+type spamState struct {
+	tokens  float64
+	last    time.Time
+	content string
+	repeats int
+}
+
+// NewSpamGuard allows bursts of 10 messages refilling at 1/s and three
+// identical messages in a row per minute.
+func NewSpamGuard() *SpamGuard {
+	return &SpamGuard{Rate: 1, Burst: 10, MaxRepeats: 3, RepeatWindow: time.Minute}
+}
+
+func (g *SpamGuard) Process(_ context.Context, d *Draft) error {
+	// edits neither flood a room nor repeat a message
+	if d.Op == OpEdit {
+		return nil
+	}
+	now := time.Now()
+	if g.now != nil {
+		now = g.now()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.users == nil {
+		g.users = make(map[int64]*spamState)
+	}
+	g.prune(now)
+
+	st, ok := g.users[d.UserID]
+	if !ok {
+		st = &spamState{tokens: g.Burst, last: now}
+		g.users[d.UserID] = st
+	}
+	st.tokens = min(g.Burst, st.tokens+now.Sub(st.last).Seconds()*g.Rate)
+	repeat := d.Content != "" && d.Content == st.content && now.Sub(st.last) < g.RepeatWindow
+	st.last = now
+
+	if st.tokens < 1 {
+		return &Error{Code: CodeRateLimited, Msg: "sending too fast"}
+	}
+	if repeat && st.repeats >= g.MaxRepeats {
+		return &Error{Code: CodeRateLimited, Msg: "duplicate message"}
+	}
+	st.tokens--
+	if repeat {
+		st.repeats++
+	} else {
+		st.content, st.repeats = d.Content, 1
+	}
+	return nil
+}
+
+// prune drops users idle long enough to be back at a full bucket.
+func (g *SpamGuard) prune(now time.Time) {
+	if len(g.users) < 1024 {
+		return
+	}
+	idle := max(time.Duration(g.Burst/g.Rate*float64(time.Second)), g.RepeatWindow)
+	for id, st := range g.users {
+		if now.Sub(st.last) > idle {
+			delete(g.users, id)
+		}
+	}
+}
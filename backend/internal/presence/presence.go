@@ -0,0 +1,179 @@
+// Package presence derives online/away/dnd/offline from live WebSocket
+// connections. State lives in Redis so every backend instance sees the
+// connections of the others.
+package presence
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusDND     = "dnd"
+	StatusOffline = "offline"
+)
+
+const (
+	// HeartbeatInterval is how often a live connection refreshes its entry.
+	HeartbeatInterval = 30 * time.Second
+	// ConnTTL drops connections whose instance died without cleaning up.
+	ConnTTL = 90 * time.Second
+)
+
+var ErrInvalidStatus = errors.New("invalid presence status")
+
+type Tracker struct {
+	RDB *redis.Client
+}
+
+// keys: per-user ZSET of connection ids scored by expiry, and the manual status
+func connsKey(userID int64) string  { return "presence:conns:" + strconv.FormatInt(userID, 10) }
+func statusKey(userID int64) string { return "presence:status:" + strconv.FormatInt(userID, 10) }
+
+// roomKey is a per-room ZSET of "userID/connID" scored by expiry, for @here.
+func roomKey(roomID int64) string { return "presence:room:" + strconv.FormatInt(roomID, 10) }
+
+func roomMember(userID int64, connID string) string {
+	return strconv.FormatInt(userID, 10) + "/" + connID
+}
+
+func nowScore(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+// Connect records a live connection. changed is true when the user was offline.
+func (t *Tracker) Connect(ctx context.Context, userID int64, connID string) (status string, changed bool, err error) {
+	now := time.Now()
+	key := connsKey(userID)
+
+	pipe := t.RDB.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", nowScore(now))
+	card := pipe.ZCard(ctx, key)
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(ConnTTL).Unix()), Member: connID})
+	pipe.Expire(ctx, key, ConnTTL)
+	manual := pipe.Get(ctx, statusKey(userID))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return "", false, err
+	}
+	return effective(1, manual.Val()), card.Val() == 0, nil
+}
+
+// Heartbeat keeps a connection alive past ConnTTL.
+func (t *Tracker) Heartbeat(ctx context.Context, userID int64, connID string) error {
+	key := connsKey(userID)
+	pipe := t.RDB.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().Add(ConnTTL).Unix()), Member: connID})
+	pipe.Expire(ctx, key, ConnTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Disconnect drops a connection. changed is true when it was the user's last one.
+func (t *Tracker) Disconnect(ctx context.Context, userID int64, connID string) (changed bool, err error) {
+	key := connsKey(userID)
+	pipe := t.RDB.TxPipeline()
+	rem := pipe.ZRem(ctx, key, connID)
+	pipe.ZRemRangeByScore(ctx, key, "-inf", nowScore(time.Now()))
+	card := pipe.ZCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return rem.Val() > 0 && card.Val() == 0, nil
+}
+
+// EnterRoom records that a connection is subscribed to the room. Calling it
+// again refreshes the entry, so it doubles as the room heartbeat.
+func (t *Tracker) EnterRoom(ctx context.Context, roomID, userID int64, connID string) error {
+	key := roomKey(roomID)
+	pipe := t.RDB.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().Add(ConnTTL).Unix()), Member: roomMember(userID, connID)})
+	pipe.Expire(ctx, key, ConnTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// LeaveRoom drops a connection's room subscription.
+func (t *Tracker) LeaveRoom(ctx context.Context, roomID, userID int64, connID string) error {
+	return t.RDB.ZRem(ctx, roomKey(roomID), roomMember(userID, connID)).Err()
+}
+
+// RoomUsers returns the distinct users with a live connection to the room,
+// on any instance.
+func (t *Tracker) RoomUsers(ctx context.Context, roomID int64) ([]int64, error) {
+	members, err := t.RDB.ZRangeByScore(ctx, roomKey(roomID), &redis.ZRangeBy{
+		Min: "(" + nowScore(time.Now()),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool, len(members))
+	var out []int64
+	for _, m := range members {
+		uid, _, _ := strings.Cut(m, "/")
+		id, err := strconv.ParseInt(uid, 10, 64)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// Get returns the effective status of each user.
+func (t *Tracker) Get(ctx context.Context, userIDs []int64) (map[int64]string, error) {
+	now := nowScore(time.Now())
+	pipe := t.RDB.Pipeline()
+	live := make([]*redis.IntCmd, len(userIDs))
+	manual := make([]*redis.StringCmd, len(userIDs))
+	for i, id := range userIDs {
+		live[i] = pipe.ZCount(ctx, connsKey(id), "("+now, "+inf")
+		manual[i] = pipe.Get(ctx, statusKey(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	out := make(map[int64]string, len(userIDs))
+	for i, id := range userIDs {
+		out[id] = effective(live[i].Val(), manual[i].Val())
+	}
+	return out, nil
+}
+
+// SetManual sets away/dnd, or clears it with online. The returned status is
+// the effective one, which stays offline while the user has no connection.
+func (t *Tracker) SetManual(ctx context.Context, userID int64, status string) (string, error) {
+	switch status {
+	case StatusOnline:
+		if err := t.RDB.Del(ctx, statusKey(userID)).Err(); err != nil {
+			return "", err
+		}
+	case StatusAway, StatusDND:
+		if err := t.RDB.Set(ctx, statusKey(userID), status, 0).Err(); err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrInvalidStatus
+	}
+	st, err := t.Get(ctx, []int64{userID})
+	if err != nil {
+		return "", err
+	}
+	return st[userID], nil
+}
+
+func effective(live int64, manual string) string {
+	if live == 0 {
+		return StatusOffline
+	}
+	if manual == StatusAway || manual == StatusDND {
+		return manual
+	}
+	return StatusOnline
+}
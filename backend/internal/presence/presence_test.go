@@ -0,0 +1,83 @@
+package presence
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func setup(t *testing.T) *Tracker {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return &Tracker{RDB: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+}
+
+func TestTracker_MultipleConnections(t *testing.T) {
+	tr := setup(t)
+	ctx := context.Background()
+
+	st, changed, err := tr.Connect(ctx, 7, "a:1")
+	if err != nil || !changed || st != StatusOnline {
+		t.Fatalf("first connect: status=%s changed=%v err=%v", st, changed, err)
+	}
+	if _, changed, _ = tr.Connect(ctx, 7, "b:1"); changed {
+		t.Fatalf("second device must not change presence")
+	}
+	if changed, _ = tr.Disconnect(ctx, 7, "a:1"); changed {
+		t.Fatalf("still connected on b:1, must not change presence")
+	}
+	if changed, _ = tr.Disconnect(ctx, 7, "b:1"); !changed {
+		t.Fatalf("last disconnect must change presence")
+	}
+	got, err := tr.Get(ctx, []int64{7})
+	if err != nil || got[7] != StatusOffline {
+		t.Fatalf("want offline, got %v err=%v", got, err)
+	}
+}
+
+func TestTracker_ManualStatus(t *testing.T) {
+	tr := setup(t)
+	ctx := context.Background()
+
+	if st, _ := tr.SetManual(ctx, 7, StatusDND); st != StatusOffline {
+		t.Fatalf("dnd while disconnected must read offline, got %s", st)
+	}
+	if st, _, _ := tr.Connect(ctx, 7, "a:1"); st != StatusDND {
+		t.Fatalf("want dnd after connect, got %s", st)
+	}
+	if st, _ := tr.SetManual(ctx, 7, StatusOnline); st != StatusOnline {
+		t.Fatalf("want online after clearing, got %s", st)
+	}
+	if _, err := tr.SetManual(ctx, 7, "busy"); err != ErrInvalidStatus {
+		t.Fatalf("want ErrInvalidStatus, got %v", err)
+	}
+}
+
+func TestTracker_RoomUsers(t *testing.T) {
+	tr := setup(t)
+	ctx := context.Background()
+
+	// two instances, one user on both
+	_ = tr.EnterRoom(ctx, 1, 7, "a:1")
+	_ = tr.EnterRoom(ctx, 1, 7, "b:1")
+	_ = tr.EnterRoom(ctx, 1, 8, "b:2")
+	_ = tr.EnterRoom(ctx, 2, 9, "a:2")
+
+	got, err := tr.RoomUsers(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []int64{7, 8}) {
+		t.Fatalf("room 1 users = %v, want [7 8]", got)
+	}
+
+	_ = tr.LeaveRoom(ctx, 1, 8, "b:2")
+	_ = tr.LeaveRoom(ctx, 1, 7, "a:1")
+	if got, _ = tr.RoomUsers(ctx, 1); !slices.Equal(got, []int64{7}) {
+		t.Fatalf("after leaving, room 1 users = %v, want [7]", got)
+	}
+}
@@ -0,0 +1,117 @@
+// Package search parses message search queries and renders result snippets.
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+)
+
+var ErrEmptyQuery = errors.New("empty search query")
+
+// Query is a parsed search. Text keeps the free-text part, quoted phrases
+// included, in the syntax of Postgres websearch_to_tsquery.
+type Query struct {
+	Text   string
+	From   string // username, lower-cased
+	In     string // room name, lower-cased
+	Before *time.Time
+	After  *time.Time
+}
+
+// Parse splits q into free text and the from:, in:, before: and after:
+// filters. Dates are YYYY-MM-DD (UTC midnight) or RFC3339. A filter with an
+// unparsable value is an error; unknown prefixes are kept as text.
+func Parse(q string) (Query, error) {
+	var (
+		out  Query
+		text []string
+	)
+	for _, tok := range tokenize(q) {
+		key, val, ok := strings.Cut(tok, ":")
+		if !ok || val == "" || strings.HasPrefix(tok, `"`) {
+			text = append(text, tok)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			out.From = strings.ToLower(strings.TrimPrefix(val, "@"))
+		case "in":
+			out.In = strings.ToLower(strings.TrimPrefix(val, "#"))
+		case "before", "after":
+			t, err := parseDate(val)
+			if err != nil {
+				return Query{}, errors.New("invalid " + strings.ToLower(key) + " date")
+			}
+			if strings.ToLower(key) == "before" {
+				out.Before = &t
+			} else {
+				out.After = &t
+			}
+		default:
+			text = append(text, tok)
+		}
+	}
+	out.Text = strings.Join(text, " ")
+	if strings.Trim(out.Text, `" `) == "" {
+		return Query{}, ErrEmptyQuery
+	}
+	return out, nil
+}
+
+// tokenize splits on whitespace but keeps "quoted phrases" whole.
+func tokenize(q string) []string {
+	var (
+		toks    []string
+		cur     strings.Builder
+		inQuote bool
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			toks = append(toks, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			cur.WriteRune(r)
+			if inQuote {
+				flush()
+			}
+			inQuote = !inQuote
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuote {
+		cur.WriteRune('"')
+	}
+	flush()
+	return toks
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Snippet delimiters handed to ts_headline: private-use runes, which real
+// message text does not contain and html.EscapeString leaves alone.
+const (
+	StartSel = "\ue000"
+	StopSel  = "\ue001"
+)
+
+// Highlight escapes a ts_headline fragment and turns its delimiters into
+// <mark> tags, so the snippet is safe to render as HTML.
+func Highlight(raw string) string {
+	s := html.EscapeString(raw)
+	s = strings.ReplaceAll(s, StartSel, "<mark>")
+	return strings.ReplaceAll(s, StopSel, "</mark>")
+}
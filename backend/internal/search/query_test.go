@@ -0,0 +1,52 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Filters(t *testing.T) {
+	q, err := Parse(`from:@Alice in:#General "launch plan" budget before:2026-03-01 after:2026-01-15T08:00:00Z`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.Text != `"launch plan" budget` {
+		t.Errorf("text = %q", q.Text)
+	}
+	if q.From != "alice" || q.In != "general" {
+		t.Errorf("from=%q in=%q", q.From, q.In)
+	}
+	if q.Before == nil || !q.Before.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("before = %v", q.Before)
+	}
+	if q.After == nil || !q.After.Equal(time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("after = %v", q.After)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []string{"", "   ", "from:alice", `""`, "deploy before:yesterday"}
+	for _, in := range cases {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q): want error", in)
+		}
+	}
+}
+
+func TestParse_KeepsUnknownPrefixesAndQuotedColons(t *testing.T) {
+	q, err := Parse(`http://example.com "from:nobody" to:do`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if q.Text != `http://example.com "from:nobody" to:do` || q.From != "" {
+		t.Errorf("text=%q from=%q", q.Text, q.From)
+	}
+}
+
+func TestHighlight_EscapesContent(t *testing.T) {
+	got := Highlight("<b>x</b> " + StartSel + "deploy" + StopSel + " & go")
+	want := "&lt;b&gt;x&lt;/b&gt; <mark>deploy</mark> &amp; go"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
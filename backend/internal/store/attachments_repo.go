@@ -0,0 +1,218 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+// MaxAttachmentsPerMessage caps NewMessage.AttachmentIDs.
+const MaxAttachmentsPerMessage = 10
+
+const attachmentColumns = `id, room_id, message_id, uploader_id, filename, content_type, size_bytes, storage_key, thumb_key, width, height, created_at`
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var (
+		a             models.Attachment
+		msgID         sql.NullInt64
+		thumbKey      sql.NullString
+		width, height sql.NullInt32
+	)
+	if err := row.Scan(&a.ID, &a.RoomID, &msgID, &a.UploaderID, &a.Filename, &a.ContentType, &a.Size,
+		&a.StorageKey, &thumbKey, &width, &height, &a.CreatedAt); err != nil {
+		return a, err
+	}
+	if msgID.Valid {
+		a.MessageID = &msgID.Int64
+	}
+	if thumbKey.Valid {
+		a.ThumbKey = &thumbKey.String
+		a.HasThumbnail = true
+	}
+	if width.Valid && height.Valid {
+		w, h := int(width.Int32), int(height.Int32)
+		a.Width, a.Height = &w, &h
+	}
+	return a, nil
+}
+
+// CreateAttachment records an uploaded blob, unlinked until a message claims it.
+func (s *Store) CreateAttachment(ctx context.Context, a models.Attachment) (models.Attachment, error) {
+	return scanAttachment(s.DB.QueryRowContext(ctx, `
+		INSERT INTO attachments (room_id, uploader_id, filename, content_type, size_bytes, storage_key, thumb_key, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+attachmentColumns,
+		a.RoomID, a.UploaderID, a.Filename, a.ContentType, a.Size, a.StorageKey, a.ThumbKey, a.Width, a.Height))
+}
+
+// GetAttachment returns an attachment the viewer may download: its room must
+// be visible, and it must belong to a live, unexpired message or be the
+// viewer's own pending upload.
+func (s *Store) GetAttachment(ctx context.Context, roomID, attID, viewerID int64) (models.Attachment, error) {
+	a, err := scanAttachment(s.DB.QueryRowContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments a
+		WHERE a.id = $1 AND a.room_id = $2
+		  AND `+roomVisible("$2", "$3")+`
+		  AND (
+			(a.message_id IS NULL AND a.uploader_id = $3)
+			OR EXISTS (
+				SELECT 1 FROM messages m
+				WHERE m.id = a.message_id AND m.deleted_at IS NULL
+				  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+			)
+		  )
+	`, attID, roomID, viewerID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNotFound
+	}
+	return a, err
+}
+
+// CleanupAttachments removes up to batch attachments nobody can download
+// any more: those of deleted or expired messages, and uploads still unsent
+// at staleBefore. remove gets their storage keys (thumbnails included) and
+// must delete the files; the rows only go once it succeeded, so a failure
+// leaves them for the next run. It returns how many attachments went.
+func (s *Store) CleanupAttachments(ctx context.Context, staleBefore time.Time, batch int, remove func(keys []string) error) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the row locks make a concurrent send of a stale upload wait, then miss it
+	n, err := deleteAttachments(ctx, tx, remove, `
+		SELECT a.id, a.storage_key, a.thumb_key
+		FROM attachments a
+		LEFT JOIN messages m ON m.id = a.message_id
+		WHERE (a.message_id IS NULL AND a.created_at < $1)
+		   OR m.deleted_at IS NOT NULL
+		   OR m.expires_at <= NOW()
+		ORDER BY a.id
+		LIMIT $2
+		FOR UPDATE OF a SKIP LOCKED
+	`, staleBefore, batch)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// deleteAttachments deletes the attachments query selects (id, storage_key,
+// thumb_key, locked): files first through remove, then the rows.
+func deleteAttachments(ctx context.Context, tx *sql.Tx, remove func(keys []string) error, query string, args ...any) (int, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var (
+		ids  []int64
+		keys []string
+	)
+	for rows.Next() {
+		var (
+			id       int64
+			key      string
+			thumbKey sql.NullString
+		)
+		if err := rows.Scan(&id, &key, &thumbKey); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		keys = append(keys, key)
+		if thumbKey.Valid {
+			keys = append(keys, thumbKey.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := remove(keys); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM attachments WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// linkAttachments claims the author's pending uploads in the room for m.
+// Anything else in ids (foreign, already sent, other room) fails the whole
+// message with ErrInvalidAttachment.
+func linkAttachments(ctx context.Context, tx *sql.Tx, m *models.Message, ids []int64) error {
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE attachments SET message_id = $1
+		WHERE id = ANY($2) AND room_id = $3 AND uploader_id = $4 AND message_id IS NULL
+		RETURNING `+attachmentColumns,
+		m.ID, pq.Array(ids), m.RoomID, m.UserID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return err
+		}
+		m.Attachments = append(m.Attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(m.Attachments) != len(ids) {
+		return ErrInvalidAttachment
+	}
+	return nil
+}
+
+// attachAttachments fills Attachments on items with one query.
+// Tombstones keep none.
+func (s *Store) attachAttachments(ctx context.Context, items []models.Message) error {
+	idx := make(map[int64]int, len(items))
+	ids := make([]int64, 0, len(items))
+	for i, m := range items {
+		if m.Deleted {
+			continue
+		}
+		idx[m.ID] = i
+		ids = append(ids, m.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE message_id = ANY($1)
+		ORDER BY id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return err
+		}
+		if i, ok := idx[*a.MessageID]; ok {
+			items[i].Attachments = append(items[i].Attachments, a)
+		}
+	}
+	return rows.Err()
+}
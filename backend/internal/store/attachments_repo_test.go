@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var cleanupCols = []string{"id", "storage_key", "thumb_key"}
+
+func TestCleanupAttachments_FilesThenRows(t *testing.T) {
+	s, mock := newMockStore(t)
+	stale := time.Now().Add(-24 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM attachments a\s+LEFT JOIN messages m(.+)a.message_id IS NULL AND a.created_at < \$1(.+)m.deleted_at IS NOT NULL(.+)m.expires_at <= NOW\(\)(.+)FOR UPDATE OF a SKIP LOCKED`).
+		WithArgs(stale, 100).
+		WillReturnRows(sqlmock.NewRows(cleanupCols).
+			AddRow(1, "rooms/1/a", "rooms/1/a.thumb.jpg").
+			AddRow(2, "rooms/1/b", nil))
+	mock.ExpectExec(`DELETE FROM attachments WHERE id = ANY\(\$1\)`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	var removed []string
+	n, err := s.CleanupAttachments(context.Background(), stale, 100, func(keys []string) error {
+		removed = keys
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"rooms/1/a", "rooms/1/a.thumb.jpg", "rooms/1/b"}; n != 2 || !slices.Equal(removed, want) {
+		t.Fatalf("n=%d removed=%v", n, removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCleanupAttachments_KeepsRowsWhenFilesRemain(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM attachments a`).
+		WillReturnRows(sqlmock.NewRows(cleanupCols).AddRow(1, "rooms/1/a", nil))
+	// no DELETE: the rows stay until their files are gone
+	mock.ExpectRollback()
+
+	storageDown := errors.New("storage down")
+	_, err := s.CleanupAttachments(context.Background(), time.Now(), 100, func([]string) error { return storageDown })
+	if !errors.Is(err, storageDown) {
+		t.Fatalf("want storage error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestGetAttachment_OnlyForWhoCanSeeIt(t *testing.T) {
+	s, mock := newMockStore(t)
+	// room visibility, and a live unexpired message or the viewer's own pending upload
+	mock.ExpectQuery(`FROM attachments a\s+WHERE a.id = \$1 AND a.room_id = \$2\s+AND EXISTS \(\s*SELECT 1 FROM rooms vr\s*WHERE vr.id = \$2(.+)a.message_id IS NULL AND a.uploader_id = \$3(.+)m.deleted_at IS NULL\s+AND \(m.expires_at IS NULL OR m.expires_at > NOW\(\)\)`).
+		WithArgs(int64(5), int64(1), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := s.GetAttachment(context.Background(), 1, 5, 8); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
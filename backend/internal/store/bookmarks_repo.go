@@ -0,0 +1,155 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var bookmarkColumns = mMessageColumns + `, b.user_id, b.created_at, b.remind_at, b.reminded_at`
+
+func scanBookmark(row rowScanner) (models.Bookmark, error) {
+	var (
+		b                    models.Bookmark
+		remindAt, remindedAt sql.NullTime
+	)
+	m, err := scanMessage(trailingRow{row, []any{&b.UserID, &b.CreatedAt, &remindAt, &remindedAt}})
+	if err != nil {
+		return b, err
+	}
+	b.Message = m
+	if remindAt.Valid {
+		b.RemindAt = &remindAt.Time
+	}
+	if remindedAt.Valid {
+		b.RemindedAt = &remindedAt.Time
+	}
+	return b, nil
+}
+
+// SaveBookmark bookmarks a live message the user can see. Saving again
+// keeps the bookmark's position and replaces its reminder.
+func (s *Store) SaveBookmark(ctx context.Context, userID, msgID int64, remindAt *time.Time) (models.Bookmark, error) {
+	b, err := scanBookmark(s.DB.QueryRowContext(ctx, `
+		WITH b AS (
+			INSERT INTO bookmarks (user_id, message_id, remind_at)
+			SELECT $1, m.id, $3::timestamptz
+			FROM messages m
+			WHERE m.id = $2 AND m.deleted_at IS NULL
+			  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+			  AND `+roomVisible("m.room_id", "$1")+`
+			ON CONFLICT (user_id, message_id)
+			DO UPDATE SET remind_at = EXCLUDED.remind_at, reminded_at = NULL
+			RETURNING user_id, message_id, created_at, remind_at, reminded_at
+		)
+		SELECT `+bookmarkColumns+`
+		FROM b JOIN messages m ON m.id = b.message_id
+	`, userID, msgID, remindAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Bookmark{}, ErrNotFound
+	}
+	return b, err
+}
+
+// DeleteBookmark removes a bookmark; removed=false if there was none.
+func (s *Store) DeleteBookmark(ctx context.Context, userID, msgID int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM bookmarks WHERE user_id = $1 AND message_id = $2
+	`, userID, msgID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListBookmarks returns the user's bookmarks, newest first, paging backwards
+// from (beforeTS, beforeID). Access is checked on read, so bookmarks in rooms
+// the user has left, or of deleted or expired messages, drop out of the list.
+func (s *Store) ListBookmarks(ctx context.Context, userID int64, limit int, beforeTS *time.Time, beforeID *int64) ([]models.Bookmark, error) {
+	args := []any{userID}
+	query := `
+		SELECT ` + bookmarkColumns + `
+		FROM bookmarks b
+		JOIN messages m ON m.id = b.message_id
+		WHERE b.user_id = $1 AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		  AND ` + roomVisible("m.room_id", "$1")
+	if beforeTS != nil && beforeID != nil {
+		args = append(args, *beforeTS, *beforeID)
+		query += ` AND (b.created_at, b.message_id) < ($2, $3)`
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY b.created_at DESC, b.message_id DESC LIMIT $%d`, len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Bookmark
+	for rows.Next() {
+		b, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]models.Message, len(out))
+	for i, b := range out {
+		items[i] = b.Message
+	}
+	if err := s.hydrate(ctx, userID, items); err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Message = items[i]
+	}
+	return out, nil
+}
+
+// ClaimDueReminders marks up to batch reminders due at now as sent and
+// returns those the user may still see. SKIP LOCKED lets several instances
+// claim concurrently without sending a reminder twice.
+func (s *Store) ClaimDueReminders(ctx context.Context, now time.Time, batch int) ([]models.Bookmark, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH b AS (
+			UPDATE bookmarks SET reminded_at = NOW()
+			WHERE (user_id, message_id) IN (
+				SELECT user_id, message_id FROM bookmarks
+				WHERE remind_at <= $1 AND reminded_at IS NULL
+				ORDER BY remind_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING user_id, message_id, created_at, remind_at, reminded_at
+		)
+		SELECT `+bookmarkColumns+`
+		FROM b JOIN messages m ON m.id = b.message_id
+		WHERE m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		  AND `+roomVisible("m.room_id", "b.user_id"),
+		now, batch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Bookmark
+	for rows.Next() {
+		b, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
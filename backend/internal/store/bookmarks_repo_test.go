@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var bookmarkCols = append(append([]string{}, messageCols...), "user_id", "created_at", "remind_at", "reminded_at")
+
+func TestSaveBookmark_NotVisible(t *testing.T) {
+	s, mock := newMockStore(t)
+	at := time.Now().Add(time.Hour)
+	mock.ExpectQuery(`INSERT INTO bookmarks (.+) ON CONFLICT`).
+		WithArgs(int64(7), int64(10), &at).
+		WillReturnRows(sqlmock.NewRows(bookmarkCols))
+
+	if _, err := s.SaveBookmark(context.Background(), 7, 10, &at); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListBookmarks_KeysetAndVisibility(t *testing.T) {
+	s, mock := newMockStore(t)
+	saved := time.Now().Add(-time.Minute)
+	remind := time.Now().Add(time.Hour)
+	beforeTS, beforeID := time.Now(), int64(99)
+
+	mock.ExpectQuery(`FROM bookmarks b\s+JOIN messages m ON m.id = b.message_id\s+WHERE b.user_id = \$1 AND m.deleted_at IS NULL\s+AND \(m.expires_at IS NULL OR m.expires_at > NOW\(\)\)\s+AND EXISTS (.+) AND \(b.created_at, b.message_id\) < \(\$2, \$3\) ORDER BY b.created_at DESC, b.message_id DESC LIMIT \$4`).
+		WithArgs(int64(7), beforeTS, beforeID, 21).
+		WillReturnRows(sqlmock.NewRows(bookmarkCols).
+			AddRow(10, 1, 3, nil, nil, "read later", saved, nil, nil, nil, nil, 7, saved, remind, nil))
+	mock.ExpectQuery(`FROM message_reactions`).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "emoji", "count", "me"}))
+	mock.ExpectQuery(`FROM attachments`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`FROM polls`).
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	items, err := s.ListBookmarks(context.Background(), 7, 21, &beforeTS, &beforeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Message.ID != 10 || items[0].RemindAt == nil || items[0].RemindedAt != nil {
+		t.Fatalf("got %+v", items)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestClaimDueReminders_SkipsLockedRows(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Now()
+	mock.ExpectQuery(`UPDATE bookmarks SET reminded_at = NOW\(\)(.+)FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, 200).
+		WillReturnRows(sqlmock.NewRows(bookmarkCols).
+			AddRow(10, 1, 3, nil, nil, "ping", now, nil, nil, nil, nil, 7, now, now, now))
+
+	due, err := s.ClaimDueReminders(context.Background(), now, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].UserID != 7 || due[0].RemindedAt == nil {
+		t.Fatalf("got %+v", due)
+	}
+}
@@ -2,12 +2,22 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrUnknownUser = errors.New("unknown user")
+)
+
 type Store struct {
 	DB *sql.DB
+
+	// EditWindow limits how long after posting a message may be edited.
+	EditWindow time.Duration
 }
 
 func NewFormDB(db *sql.DB) *Store { return &Store{DB: db} }
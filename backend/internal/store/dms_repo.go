@@ -0,0 +1,139 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrDMParticipants rejects a DM with fewer than two distinct people.
+var ErrDMParticipants = errors.New("dm needs at least two participants")
+
+// DMKey normalizes a participant set (sorted, deduplicated) so the same
+// people always map to the same DM room regardless of order.
+func DMKey(userIDs []int64) string {
+	ids := append([]int64(nil), userIDs...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	parts := make([]string, 0, len(ids))
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// GetOrCreateDM returns the hidden DM room for the given participants,
+// creating it (and its memberships) on first use. A concurrent first use
+// loses the insert on dm_key and returns the winner's room.
+func (s *Store) GetOrCreateDM(ctx context.Context, userIDs []int64) (models.Conversation, bool, error) {
+	key := DMKey(userIDs)
+	if !strings.Contains(key, ",") {
+		return models.Conversation{}, false, ErrDMParticipants
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Conversation{}, false, err
+	}
+	defer tx.Rollback()
+
+	var roomID int64
+	created := true
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rooms (name, is_public, kind, dm_key)
+		VALUES ('dm', FALSE, 'dm', $1)
+		ON CONFLICT (dm_key) WHERE dm_key IS NOT NULL DO NOTHING
+		RETURNING id
+	`, key).Scan(&roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		created = false
+		err = tx.QueryRowContext(ctx, `SELECT id FROM rooms WHERE dm_key = $1`, key).Scan(&roomID)
+	}
+	if err != nil {
+		return models.Conversation{}, false, err
+	}
+
+	if created {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO room_members (room_id, user_id)
+			SELECT $1::bigint, unnest($2::bigint[])
+			ON CONFLICT DO NOTHING
+		`, roomID, pq.Array(userIDs)); err != nil {
+			var pqe *pq.Error
+			if errors.As(err, &pqe) && pqe.Code == "23503" { // foreign key violation
+				return models.Conversation{}, false, ErrUnknownUser
+			}
+			return models.Conversation{}, false, err
+		}
+	}
+
+	conv, err := scanConversation(tx.QueryRowContext(ctx, conversationSelect+` WHERE r.id = $1`, roomID))
+	if err != nil {
+		return models.Conversation{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Conversation{}, false, err
+	}
+	return conv, created, nil
+}
+
+// ListConversations returns the user's DMs, most recently active first.
+func (s *Store) ListConversations(ctx context.Context, userID int64) ([]models.Conversation, error) {
+	rows, err := s.DB.QueryContext(ctx, conversationSelect+`
+		JOIN room_members me ON me.room_id = r.id AND me.user_id = $1
+		WHERE r.kind = 'dm'
+		ORDER BY COALESCE(lm.created_at, r.created_at) DESC, r.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Conversation
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, conv)
+	}
+	return out, rows.Err()
+}
+
+const conversationSelect = `
+	SELECT r.id, r.created_at, lm.created_at,
+	       ARRAY(SELECT m.user_id FROM room_members m WHERE m.room_id = r.id ORDER BY m.user_id)
+	FROM rooms r
+	LEFT JOIN LATERAL (
+		SELECT created_at FROM messages
+		WHERE room_id = r.id
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	) lm ON TRUE`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConversation(row rowScanner) (models.Conversation, error) {
+	var (
+		c      models.Conversation
+		lastAt sql.NullTime
+	)
+	if err := row.Scan(&c.RoomID, &c.CreatedAt, &lastAt, pq.Array(&c.ParticipantIDs)); err != nil {
+		return c, err
+	}
+	if lastAt.Valid {
+		c.LastMessageAt = &lastAt.Time
+	}
+	return c, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDMKey(t *testing.T) {
+	cases := []struct {
+		in   []int64
+		want string
+	}{
+		{[]int64{7, 3}, "3,7"},
+		{[]int64{3, 7}, "3,7"},
+		{[]int64{12, 3, 7, 3}, "3,7,12"},
+		{[]int64{5}, "5"},
+	}
+	for _, c := range cases {
+		if got := DMKey(c.in); got != c.want {
+			t.Fatalf("DMKey(%v) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+var conversationCols = []string{"id", "created_at", "last_message_at", "participant_ids"}
+
+func TestGetOrCreateDM_CreatesRoomAndMembers(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rooms \(name, is_public, kind, dm_key\)(.+)ON CONFLICT \(dm_key\)`).
+		WithArgs("7,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectExec(`INSERT INTO room_members \(room_id, user_id\)`).
+		WithArgs(int64(40), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`FROM rooms r(.+)WHERE r.id = \$1`).
+		WithArgs(int64(40)).
+		WillReturnRows(sqlmock.NewRows(conversationCols).AddRow(40, time.Now(), nil, "{7,9}"))
+	mock.ExpectCommit()
+
+	conv, created, err := s.GetOrCreateDM(context.Background(), []int64{9, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || conv.RoomID != 40 || len(conv.ParticipantIDs) != 2 {
+		t.Fatalf("created=%v conv=%+v", created, conv)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestGetOrCreateDM_LosingTheRaceReturnsTheExistingRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	// another request inserted the same dm_key first: DO NOTHING returns no row
+	mock.ExpectQuery(`INSERT INTO rooms \(name, is_public, kind, dm_key\)`).
+		WithArgs("7,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT id FROM rooms WHERE dm_key = \$1`).
+		WithArgs("7,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	// memberships belong to the winner; nothing is inserted again
+	mock.ExpectQuery(`FROM rooms r(.+)WHERE r.id = \$1`).
+		WithArgs(int64(40)).
+		WillReturnRows(sqlmock.NewRows(conversationCols).AddRow(40, time.Now(), nil, "{7,9}"))
+	mock.ExpectCommit()
+
+	conv, created, err := s.GetOrCreateDM(context.Background(), []int64{7, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || conv.RoomID != 40 {
+		t.Fatalf("created=%v conv=%+v", created, conv)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestGetOrCreateDM_RejectsSelfDM(t *testing.T) {
+	s, mock := newMockStore(t)
+
+	for _, ids := range [][]int64{{7}, {7, 7}} {
+		if _, _, err := s.GetOrCreateDM(context.Background(), ids); !errors.Is(err, ErrDMParticipants) {
+			t.Fatalf("GetOrCreateDM(%v): want ErrDMParticipants, got %v", ids, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestGetOrCreateDM_OtherPairGetsItsOwnRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	// 7 and 9 already share a DM; 8 opening one with 9 must not land in it
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rooms \(name, is_public, kind, dm_key\)`).
+		WithArgs("8,9").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(41))
+	mock.ExpectExec(`INSERT INTO room_members \(room_id, user_id\)`).
+		WithArgs(int64(41), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`FROM rooms r(.+)WHERE r.id = \$1`).
+		WithArgs(int64(41)).
+		WillReturnRows(sqlmock.NewRows(conversationCols).AddRow(41, time.Now(), nil, "{8,9}"))
+	mock.ExpectCommit()
+
+	conv, _, err := s.GetOrCreateDM(context.Background(), []int64{8, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.RoomID == 40 {
+		t.Fatal("opened another pair's DM")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListConversations_OnlyTheCallersDMs(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`JOIN room_members me ON me.room_id = r.id AND me.user_id = \$1\s+WHERE r.kind = 'dm'`).
+		WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows(conversationCols).AddRow(41, time.Now(), nil, "{8,9}"))
+
+	convs, err := s.ListConversations(context.Background(), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 1 || convs[0].RoomID != 41 {
+		t.Fatalf("unexpected conversations %+v", convs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInviteInvalid       = errors.New("invite invalid or expired")
+	ErrInviteEmailMismatch = errors.New("invite issued to another email")
+)
+
+func newInviteCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateInvite issues an invite for inv's workspace or room; the code is generated here.
+// An empty email makes a shareable link.
+func (s *Store) CreateInvite(ctx context.Context, inv models.Invite) (models.Invite, error) {
+	code, err := newInviteCode()
+	if err != nil {
+		return models.Invite{}, err
+	}
+	inv.Code = code
+	var emailArg any
+	if inv.Email != nil {
+		if email := strings.TrimSpace(*inv.Email); email != "" {
+			inv.Email = &email
+			emailArg = email
+		} else {
+			inv.Email = nil
+		}
+	}
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO invites (code, workspace_id, room_id, email, created_by, max_uses, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, code, inv.WorkspaceID, inv.RoomID, emailArg, inv.CreatedBy, inv.MaxUses, inv.ExpiresAt).
+		Scan(&inv.ID, &inv.CreatedAt)
+	return inv, err
+}
+
+const inviteSelect = `
+	SELECT id, code, workspace_id, room_id, email, COALESCE(created_by, 0),
+	       max_uses, use_count, expires_at, revoked_at, created_at
+	FROM invites`
+
+func scanInvite(row rowScanner) (models.Invite, error) {
+	var (
+		inv                  models.Invite
+		wsID, roomID         sql.NullInt64
+		email                sql.NullString
+		maxUses              sql.NullInt32
+		expiresAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&inv.ID, &inv.Code, &wsID, &roomID, &email, &inv.CreatedBy,
+		&maxUses, &inv.UseCount, &expiresAt, &revokedAt, &inv.CreatedAt); err != nil {
+		return inv, err
+	}
+	if wsID.Valid {
+		inv.WorkspaceID = &wsID.Int64
+	}
+	if roomID.Valid {
+		inv.RoomID = &roomID.Int64
+	}
+	if email.Valid {
+		inv.Email = &email.String
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int32)
+		inv.MaxUses = &n
+	}
+	if expiresAt.Valid {
+		inv.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		inv.RevokedAt = &revokedAt.Time
+	}
+	return inv, nil
+}
+
+// ListRoomInvites returns every invite of a room, revoked and expired included.
+func (s *Store) ListRoomInvites(ctx context.Context, roomID int64) ([]models.Invite, error) {
+	rows, err := s.DB.QueryContext(ctx, inviteSelect+` WHERE room_id = $1 ORDER BY created_at DESC, id DESC`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Invite
+	for rows.Next() {
+		inv, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inv)
+	}
+	return out, rows.Err()
+}
+
+// RevokeRoomInvite stops an invite of the room from being accepted.
+func (s *Store) RevokeRoomInvite(ctx context.Context, roomID, inviteID int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE invites SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1 AND room_id = $2
+	`, inviteID, roomID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListInviteRedemptions returns who joined through an invite of the room,
+// or ErrNotFound when the invite belongs elsewhere.
+func (s *Store) ListInviteRedemptions(ctx context.Context, roomID, inviteID int64) ([]models.InviteRedemption, error) {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM invites WHERE id = $1 AND room_id = $2)
+	`, inviteID, roomID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT r.user_id, u.username, r.redeemed_at
+		FROM invite_redemptions r
+		JOIN invites i ON i.id = r.invite_id
+		JOIN users u ON u.id = r.user_id
+		WHERE r.invite_id = $1 AND i.room_id = $2
+		ORDER BY r.redeemed_at ASC
+	`, inviteID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.InviteRedemption
+	for rows.Next() {
+		var r models.InviteRedemption
+		if err := rows.Scan(&r.UserID, &r.Username, &r.RedeemedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// AcceptInvite joins the user to the invite's workspace or room and records the
+// redemption. A room invite inside a workspace also grants workspace membership,
+// otherwise the room would stay invisible. Accepting the same invite twice
+// does not consume another use.
+//
+// An email-bound invite is matched case-insensitively against the account's
+// email. Emails are not verified yet, so the binding is advisory: it stops a
+// forwarded link, not someone who signed up with the invitee's address.
+func (s *Store) AcceptInvite(ctx context.Context, code string, userID int64) (models.InviteResult, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.InviteResult{}, err
+	}
+	defer tx.Rollback()
+
+	var (
+		inviteID     int64
+		wsID, roomID sql.NullInt64
+		maxUses      sql.NullInt32
+		useCount     int
+		emailMatch   bool
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT i.id, i.workspace_id, i.room_id, i.max_uses, i.use_count, (i.email IS NULL OR lower(i.email) = lower(u.email))
+		FROM invites i
+		JOIN users u ON u.id = $2
+		WHERE i.code = $1
+		  AND i.revoked_at IS NULL
+		  AND (i.expires_at IS NULL OR i.expires_at > NOW())
+		FOR UPDATE OF i
+	`, code, userID).Scan(&inviteID, &wsID, &roomID, &maxUses, &useCount, &emailMatch)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.InviteResult{}, ErrInviteInvalid
+	}
+	if err != nil {
+		return models.InviteResult{}, err
+	}
+	if !emailMatch {
+		return models.InviteResult{}, ErrInviteEmailMismatch
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO invite_redemptions (invite_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, inviteID, userID)
+	if err != nil {
+		return models.InviteResult{}, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if maxUses.Valid && useCount >= int(maxUses.Int32) {
+			return models.InviteResult{}, ErrInviteInvalid
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE invites SET use_count = use_count + 1 WHERE id = $1`, inviteID); err != nil {
+			return models.InviteResult{}, err
+		}
+	}
+
+	var out models.InviteResult
+	if roomID.Valid {
+		var (
+			r        models.Room
+			roomWsID sql.NullInt64
+		)
+		if err := tx.QueryRowContext(ctx, `
+			SELECT id, workspace_id, name, is_public FROM rooms WHERE id = $1
+		`, roomID.Int64).Scan(&r.ID, &roomWsID, &r.Name, &r.IsPublic); err != nil {
+			return models.InviteResult{}, err
+		}
+		if roomWsID.Valid {
+			r.WorkspaceID = &roomWsID.Int64
+			wsID = roomWsID
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO room_members (room_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, r.ID, userID); err != nil {
+			return models.InviteResult{}, err
+		}
+		out.Room = &r
+	}
+	if wsID.Valid {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, wsID.Int64, userID); err != nil {
+			return models.InviteResult{}, err
+		}
+		if out.Room == nil {
+			var w models.Workspace
+			if err := tx.QueryRowContext(ctx, `
+				SELECT w.id, w.name, m.role, w.created_at
+				FROM workspaces w
+				JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = $2
+				WHERE w.id = $1
+			`, wsID.Int64, userID).Scan(&w.ID, &w.Name, &w.Role, &w.CreatedAt); err != nil {
+				return models.InviteResult{}, err
+			}
+			out.Workspace = &w
+		}
+	}
+	return out, tx.Commit()
+}
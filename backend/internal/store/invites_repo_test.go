@@ -0,0 +1,213 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var inviteLookupCols = []string{"id", "workspace_id", "room_id", "max_uses", "use_count", "email_match"}
+
+// expectInviteLookup stubs the locked invite read at the start of AcceptInvite.
+func expectInviteLookup(mock sqlmock.Sqlmock, wsID, roomID, maxUses any, useCount int, emailMatch bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`lower\(i.email\) = lower\(u.email\)(.+)FROM invites i(.+)i.revoked_at IS NULL(.+)FOR UPDATE OF i`).
+		WithArgs("abc", int64(7)).
+		WillReturnRows(sqlmock.NewRows(inviteLookupCols).AddRow(20, wsID, roomID, maxUses, useCount, emailMatch))
+}
+
+func TestCreateInvite_GeneratesCodeAndTrimsEmail(t *testing.T) {
+	s, mock := newMockStore(t)
+	wsID := int64(3)
+	email := "  bob@example.com "
+	mock.ExpectQuery(`INSERT INTO invites`).
+		WithArgs(sqlmock.AnyArg(), int64(3), nil, "bob@example.com", int64(7), nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(20, time.Now()))
+
+	inv, err := s.CreateInvite(context.Background(), models.Invite{WorkspaceID: &wsID, Email: &email, CreatedBy: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.ID != 20 || len(inv.Code) < 20 || inv.Email == nil || *inv.Email != "bob@example.com" {
+		t.Fatalf("unexpected invite %+v", inv)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCreateInvite_BlankEmailIsALink(t *testing.T) {
+	s, mock := newMockStore(t)
+	roomID := int64(1)
+	blank := "  "
+	mock.ExpectQuery(`INSERT INTO invites`).
+		WithArgs(sqlmock.AnyArg(), nil, int64(1), nil, int64(7), nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(21, time.Now()))
+
+	inv, err := s.CreateInvite(context.Background(), models.Invite{RoomID: &roomID, Email: &blank, CreatedBy: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inv.Email != nil {
+		t.Fatalf("blank email kept: %q", *inv.Email)
+	}
+}
+
+func TestAcceptInvite_JoinsWorkspace(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectInviteLookup(mock, 3, nil, nil, 0, true)
+	mock.ExpectExec(`INSERT INTO invite_redemptions`).
+		WithArgs(int64(20), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE invites SET use_count = use_count \+ 1`).
+		WithArgs(int64(20)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO workspace_members`).
+		WithArgs(int64(3), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM workspaces w`).
+		WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "created_at"}).AddRow(3, "acme", "member", time.Now()))
+	mock.ExpectCommit()
+
+	res, err := s.AcceptInvite(context.Background(), "abc", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Workspace == nil || res.Workspace.ID != 3 || res.Workspace.Role != "member" || res.Room != nil {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAcceptInvite_TwiceUsesOnce(t *testing.T) {
+	s, mock := newMockStore(t)
+	// already redeemed: the invite is full, but the same user may accept again
+	expectInviteLookup(mock, nil, 1, 1, 1, true)
+	mock.ExpectExec(`INSERT INTO invite_redemptions`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT id, workspace_id, name, is_public FROM rooms`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "is_public"}).AddRow(1, nil, "secret", false))
+	mock.ExpectExec(`INSERT INTO room_members`).
+		WithArgs(int64(1), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	res, err := s.AcceptInvite(context.Background(), "abc", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Room == nil || res.Room.ID != 1 || res.Workspace != nil {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAcceptInvite_MaxUsesExhausted(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectInviteLookup(mock, nil, 1, 2, 2, true)
+	mock.ExpectExec(`INSERT INTO invite_redemptions`).
+		WithArgs(int64(20), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	if _, err := s.AcceptInvite(context.Background(), "abc", 7); !errors.Is(err, ErrInviteInvalid) {
+		t.Fatalf("want ErrInviteInvalid, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAcceptInvite_RevokedOrExpired(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM invites i(.+)i.revoked_at IS NULL\s+AND \(i.expires_at IS NULL OR i.expires_at > NOW\(\)\)`).
+		WithArgs("abc", int64(7)).
+		WillReturnRows(sqlmock.NewRows(inviteLookupCols))
+	mock.ExpectRollback()
+
+	if _, err := s.AcceptInvite(context.Background(), "abc", 7); !errors.Is(err, ErrInviteInvalid) {
+		t.Fatalf("want ErrInviteInvalid, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAcceptInvite_EmailMismatch(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectInviteLookup(mock, 3, nil, nil, 0, false)
+	mock.ExpectRollback()
+
+	if _, err := s.AcceptInvite(context.Background(), "abc", 7); !errors.Is(err, ErrInviteEmailMismatch) {
+		t.Fatalf("want ErrInviteEmailMismatch, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAcceptInvite_WorkspaceRoomGrantsWorkspaceMembership(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectInviteLookup(mock, nil, 1, nil, 0, true)
+	mock.ExpectExec(`INSERT INTO invite_redemptions`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE invites SET use_count`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, workspace_id, name, is_public FROM rooms`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "is_public"}).AddRow(1, 3, "ops", false))
+	mock.ExpectExec(`INSERT INTO room_members`).
+		WithArgs(int64(1), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO workspace_members`).
+		WithArgs(int64(3), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	res, err := s.AcceptInvite(context.Background(), "abc", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Room == nil || res.Room.WorkspaceID == nil || *res.Room.WorkspaceID != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRevokeRoomInvite_OtherRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectExec(`UPDATE invites SET revoked_at = COALESCE\(revoked_at, NOW\(\)\)\s+WHERE id = \$1 AND room_id = \$2`).
+		WithArgs(int64(20), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.RevokeRoomInvite(context.Background(), 2, 20); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestListInviteRedemptions_OtherRoomsInvite(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM invites WHERE id = \$1 AND room_id = \$2\)`).
+		WithArgs(int64(20), int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	if _, err := s.ListInviteRedemptions(context.Background(), 2, 20); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"backend/internal/mention"
+	"backend/internal/models"
+	"context"
+
+	"github.com/lib/pq"
+)
+
+// MaxChannelMentions caps how many users one @channel notifies.
+const MaxChannelMentions = 500
+
+// RecordMentions resolves the parsed mentions of m to users who can see the
+// room and stores them. @channel reaches the room's members and the users who
+// have read it, at most MaxChannelMentions of them; online are the users
+// connected to the room on any instance, for @here. The author is never
+// notified; a direct @user wins over @here/@channel.
+func (s *Store) RecordMentions(ctx context.Context, m models.Message, p mention.Parsed, online []int64) ([]models.Mention, error) {
+	if p.Empty() || m.Deleted {
+		return nil, nil
+	}
+	kinds := map[int64]string{}
+	put := func(uid int64, kind string) {
+		if uid == m.UserID {
+			return
+		}
+		if cur, ok := kinds[uid]; !ok || cur != mention.KindUser {
+			kinds[uid] = kind
+		}
+	}
+
+	if p.Channel {
+		// public rooms have few member rows, and every account can see them:
+		// readers stand in for the members there
+		ids, err := s.queryIDs(ctx, `
+			SELECT p.user_id FROM (
+				SELECT user_id FROM room_members WHERE room_id = $1
+				UNION
+				SELECT user_id FROM room_reads WHERE room_id = $1
+			) p
+			WHERE `+roomVisible("$1", "p.user_id")+`
+			LIMIT $2
+		`, m.RoomID, MaxChannelMentions)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			put(id, mention.KindChannel)
+		}
+	}
+	if p.Here {
+		for _, id := range online {
+			put(id, mention.KindHere)
+		}
+	}
+	if len(p.Usernames) > 0 {
+		ids, err := s.queryIDs(ctx, `
+			SELECT id FROM users WHERE LOWER(username) = ANY($1)
+		`, pq.Array(p.Usernames))
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			put(id, mention.KindUser)
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	userIDs := make([]int64, 0, len(kinds))
+	kindArr := make([]string, 0, len(kinds))
+	for uid, k := range kinds {
+		userIDs = append(userIDs, uid)
+		kindArr = append(kindArr, k)
+	}
+
+	// only users who can see the room get a mention
+	rows, err := s.DB.QueryContext(ctx, `
+		INSERT INTO mentions (message_id, room_id, user_id, kind)
+		SELECT $1::bigint, $2::bigint, t.uid, t.kind
+		FROM unnest($3::bigint[], $4::text[]) AS t(uid, kind)
+		WHERE `+roomVisible("$2", "t.uid")+`
+		ON CONFLICT DO NOTHING
+		RETURNING id, user_id, kind, created_at
+	`, m.ID, m.RoomID, pq.Array(userIDs), pq.Array(kindArr))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Mention
+	for rows.Next() {
+		mn := models.Mention{MessageID: m.ID, RoomID: m.RoomID, AuthorID: m.UserID, Excerpt: excerpt(m.Content)}
+		if err := rows.Scan(&mn.ID, &mn.UserID, &mn.Kind, &mn.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, mn)
+	}
+	return out, rows.Err()
+}
+
+// ListUnreadMentions returns the user's unread mentions in rooms they can
+// still see, newest first. Mentions of deleted or expired messages are
+// skipped.
+func (s *Store) ListUnreadMentions(ctx context.Context, userID int64, limit int) ([]models.Mention, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT mn.id, mn.message_id, mn.room_id, mn.user_id, m.user_id, mn.kind, m.content, mn.created_at
+		FROM mentions mn
+		JOIN messages m ON m.id = mn.message_id AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		WHERE mn.user_id = $1 AND mn.read_at IS NULL
+		  AND `+roomVisible("mn.room_id", "$1")+`
+		ORDER BY mn.created_at DESC, mn.id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Mention
+	for rows.Next() {
+		var (
+			mn      models.Mention
+			content string
+		)
+		if err := rows.Scan(&mn.ID, &mn.MessageID, &mn.RoomID, &mn.UserID, &mn.AuthorID, &mn.Kind, &content, &mn.CreatedAt); err != nil {
+			return nil, err
+		}
+		mn.Excerpt = excerpt(content)
+		out = append(out, mn)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) queryIDs(ctx context.Context, q string, args ...any) ([]int64, error) {
+	rows, err := s.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"backend/internal/mention"
+	"backend/internal/models"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRecordMentions_ChannelReachesMembersAndReaders(t *testing.T) {
+	s, mock := newMockStore(t)
+	m := models.Message{ID: 10, RoomID: 1, UserID: 7, Content: "@channel standup"}
+
+	mock.ExpectQuery(`SELECT user_id FROM room_members WHERE room_id = \$1\s+UNION\s+SELECT user_id FROM room_reads WHERE room_id = \$1(.+)vr.id = \$1(.+)LIMIT \$2`).
+		WithArgs(int64(1), MaxChannelMentions).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7).AddRow(8).AddRow(9))
+	mock.ExpectQuery(`INSERT INTO mentions`).
+		WithArgs(int64(10), int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "created_at"}).
+			AddRow(1, 8, "channel", time.Now()).
+			AddRow(2, 9, "channel", time.Now()))
+
+	ms, err := s.RecordMentions(context.Background(), m, mention.Parse(m.Content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].AuthorID != 7 || ms[0].Excerpt != "@channel standup" {
+		t.Fatalf("unexpected mentions %+v", ms)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRecordMentions_ChannelInPublicRoomSkipsStrangers(t *testing.T) {
+	s, mock := newMockStore(t)
+	m := models.Message{ID: 10, RoomID: 1, UserID: 7, Content: "@channel deploy at 5"}
+
+	// a public room nobody joined or read: no candidates, nothing written,
+	// however many accounts could see the room
+	mock.ExpectQuery(`FROM room_members WHERE room_id = \$1(.+)FROM room_reads`).
+		WithArgs(int64(1), MaxChannelMentions).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	ms, err := s.RecordMentions(context.Background(), m, mention.Parse(m.Content), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 0 {
+		t.Fatalf("unexpected mentions %+v", ms)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRecordMentions_HereUsesOnlineUsers(t *testing.T) {
+	s, mock := newMockStore(t)
+	m := models.Message{ID: 10, RoomID: 1, UserID: 7, Content: "@here lunch?"}
+
+	// only the insert runs: @here candidates come from the caller
+	mock.ExpectQuery(`INSERT INTO mentions`).
+		WithArgs(int64(10), int64(1), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "created_at"}).
+			AddRow(1, 8, "here", time.Now()))
+
+	ms, err := s.RecordMentions(context.Background(), m, mention.Parse(m.Content), []int64{7, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].UserID != 8 || ms[0].Kind != "here" {
+		t.Fatalf("unexpected mentions %+v", ms)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
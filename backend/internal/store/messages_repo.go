@@ -1,33 +1,155 @@
 package store
 
 import (
+	"backend/internal/markdown"
 	"backend/internal/models"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-func (s *Store) ListMessages(ctx context.Context, roomID int64, limit int, beforeTS *time.Time, beforeID *int64) ([]models.Message, error) {
-	var rows *sql.Rows
-	var err error
-	if beforeTS == nil || beforeID == nil {
-		rows, err = s.DB.QueryContext(ctx, `
-			SELECT id, room_id, user_id, content, created_at
-			FROM messages
-			WHERE room_id = $1
-			ORDER BY created_at DESC, id DESC
-			LIMIT $2
-		`, roomID, limit)
+var (
+	ErrForbidden        = errors.New("forbidden")
+	ErrEditWindowClosed = errors.New("edit window closed")
+	ErrInvalidParent    = errors.New("invalid thread parent")
+	ErrInvalidReplyTo   = errors.New("invalid reply target")
+)
+
+// DefaultEditWindow applies when Store.EditWindow is unset.
+const DefaultEditWindow = 15 * time.Minute
+
+const messageColumns = `id, room_id, user_id, parent_id, reply_to_id, content, created_at, edited_at, deleted_at, embeds, expires_at`
+
+// mMessageColumns is messageColumns for queries that alias messages as m.
+var mMessageColumns = "m." + strings.ReplaceAll(messageColumns, ", ", ", m.")
+
+// scanMessage reads messageColumns; soft-deleted rows, and ephemeral ones
+// past their expiry that the sweeper hasn't reached yet, come back as
+// tombstones.
+func scanMessage(row rowScanner) (models.Message, error) {
+	var (
+		m                              models.Message
+		parentID, replyToID            sql.NullInt64
+		editedAt, deletedAt, expiresAt sql.NullTime
+		embeds                         []byte
+	)
+	if err := row.Scan(&m.ID, &m.RoomID, &m.UserID, &parentID, &replyToID, &m.Content, &m.CreatedAt, &editedAt, &deletedAt, &embeds, &expiresAt); err != nil {
+		return m, err
+	}
+	if len(embeds) > 0 {
+		if err := json.Unmarshal(embeds, &m.Embeds); err != nil {
+			return m, err
+		}
+	}
+	if parentID.Valid {
+		m.ParentID = &parentID.Int64
+	}
+	if replyToID.Valid {
+		m.ReplyToID = &replyToID.Int64
+	}
+	if editedAt.Valid {
+		m.EditedAt = &editedAt.Time
+	}
+	if expiresAt.Valid {
+		m.ExpiresAt = &expiresAt.Time
+	}
+	if deletedAt.Valid || (expiresAt.Valid && !expiresAt.Time.After(time.Now())) {
+		m.Deleted = true
+		m.Content = ""
+		m.Embeds = nil
+	} else {
+		m.HTML = markdown.ToHTML(m.Content)
+	}
+	return m, nil
+}
+
+// ListMessages pages backwards through a room's main timeline (thread replies
+// excluded). Ephemeral messages drop out as soon as they expire.
+func (s *Store) ListMessages(ctx context.Context, roomID, viewerID int64, limit int, beforeTS *time.Time, beforeID *int64) ([]models.Message, error) {
+	return s.listMessages(ctx, roomID, viewerID, nil, limit, beforeTS, beforeID, false)
+}
+
+// ListReplies pages backwards through the replies of a thread root.
+func (s *Store) ListReplies(ctx context.Context, roomID, parentID, viewerID int64, limit int, beforeTS *time.Time, beforeID *int64) ([]models.Message, error) {
+	return s.listMessages(ctx, roomID, viewerID, &parentID, limit, beforeTS, beforeID, false)
+}
+
+// ListMessagesAfter pages forwards from (afterTS, afterID) through the main
+// timeline (parentID nil) or a thread. Items stay newest first.
+func (s *Store) ListMessagesAfter(ctx context.Context, roomID, viewerID int64, parentID *int64, limit int, afterTS time.Time, afterID int64) ([]models.Message, error) {
+	return s.listMessages(ctx, roomID, viewerID, parentID, limit, &afterTS, &afterID, true)
+}
+
+// ListMessagesAround returns targetID with up to n messages on each side of
+// it, newest first, and whether more exist beyond either end. The target must
+// belong to the timeline (parentID nil) or thread being listed, else ErrNotFound.
+func (s *Store) ListMessagesAround(ctx context.Context, roomID, viewerID int64, parentID *int64, targetID int64, n int) (items []models.Message, hasOlder, hasNewer bool, err error) {
+	var ts time.Time
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT created_at FROM messages
+		WHERE id = $1 AND room_id = $2 AND parent_id IS NOT DISTINCT FROM $3
+		  AND `+roomVisible("$2", "$4"), targetID, roomID, parentID, viewerID).Scan(&ts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, false, err
+	}
+	// each side fetches one extra row to learn whether it was cut short
+	newer, err := s.listMessages(ctx, roomID, viewerID, parentID, n+1, &ts, &targetID, true)
+	if err != nil {
+		return nil, false, false, err
+	}
+	if hasNewer = len(newer) > n; hasNewer {
+		newer = newer[1:]
+	}
+	// ids are integers, so paging back from (ts, targetID+1) starts with the target itself
+	upTo := targetID + 1
+	older, err := s.listMessages(ctx, roomID, viewerID, parentID, n+2, &ts, &upTo, false)
+	if err != nil {
+		return nil, false, false, err
+	}
+	if hasOlder = len(older) > n+1; hasOlder {
+		older = older[:n+1]
+	}
+	return append(newer, older...), hasOlder, hasNewer, nil
+}
+
+// listMessages pages backwards from (ts, id), or forwards when after is set.
+// Either way items come back newest first.
+func (s *Store) listMessages(ctx context.Context, roomID, viewerID int64, parentID *int64, limit int, ts *time.Time, id *int64, after bool) ([]models.Message, error) {
+	args := []any{roomID, viewerID}
+	q := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE room_id = $1
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND ` + roomVisible("$1", "$2")
+	if parentID == nil {
+		q += ` AND parent_id IS NULL`
 	} else {
-		rows, err = s.DB.QueryContext(ctx, `
-			SELECT id, room_id, user_id, content, created_at
-			FROM messages
-			WHERE room_id = $1
-			  AND (created_at, id) < ($2, $3)
-			ORDER BY created_at DESC, id DESC
-			LIMIT $4
-		`, roomID, *beforeTS, *beforeID, limit)
+		args = append(args, *parentID)
+		q += fmt.Sprintf(` AND parent_id = $%d`, len(args))
 	}
+	cmp, order := "<", "DESC"
+	if after {
+		cmp, order = ">", "ASC"
+	}
+	if ts != nil && id != nil {
+		args = append(args, *ts, *id)
+		q += fmt.Sprintf(` AND (created_at, id) %s ($%d, $%d)`, cmp, len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	q += fmt.Sprintf(` ORDER BY created_at %[1]s, id %[1]s LIMIT $%[2]d`, order, len(args))
+
+	rows, err := s.DB.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -35,33 +157,356 @@ func (s *Store) ListMessages(ctx context.Context, roomID int64, limit int, befor
 
 	var items []models.Message
 	for rows.Next() {
-		var m models.Message
-		if err := rows.Scan(&m.ID, &m.RoomID, &m.UserID, &m.Content, &m.CreatedAt); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		items = append(items, m)
 	}
-	return items, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if after {
+		slices.Reverse(items)
+	}
+	if err := s.hydrate(ctx, viewerID, items); err != nil {
+		return nil, err
+	}
+	if parentID == nil {
+		return items, s.attachThreadSummaries(ctx, items)
+	}
+	return items, nil
 }
 
-func (s *Store) HasOlderMessages(ctx context.Context, roomID int64, ts time.Time, id int64) (bool, error) {
-	var has bool
-	err := s.DB.QueryRowContext(ctx, `
-		SELECT EXISTS (
-			SELECT 1 FROM messages
-			WHERE room_id = $1 AND (created_at, id) < ($2, $3)
+// hydrate fills what a listed message carries besides its row: reactions
+// (Me relative to viewerID), reply snapshots, attachments and polls.
+func (s *Store) hydrate(ctx context.Context, viewerID int64, items []models.Message) error {
+	if err := s.attachReactions(ctx, viewerID, items); err != nil {
+		return err
+	}
+	if err := s.attachReplyRefs(ctx, items); err != nil {
+		return err
+	}
+	if err := s.attachAttachments(ctx, items); err != nil {
+		return err
+	}
+	return s.attachPolls(ctx, viewerID, items)
+}
+
+// NewMessage is the input of CreateMessage.
+type NewMessage struct {
+	RoomID    int64
+	UserID    int64
+	Content   string
+	ParentID  *int64 // thread root, nil for the main timeline
+	ReplyToID *int64 // quoted message, must be live and in the same room
+	// AttachmentIDs are the author's pending uploads in this room.
+	AttachmentIDs []int64
+	// TTL makes the message ephemeral: it expires TTL after it is stored.
+	TTL time.Duration
+	// Poll makes the message a poll.
+	Poll *NewPoll
+}
+
+// CreateMessage inserts only if the author can see the room, returning
+// ErrNotFound otherwise. Replies must target a live root message of the same room.
+func (s *Store) CreateMessage(ctx context.Context, in NewMessage) (models.Message, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	m, err := insertMessage(ctx, tx, in)
+	if err != nil {
+		return m, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Message{}, err
+	}
+	items := []models.Message{m}
+	if err := s.attachReplyRefs(ctx, items); err != nil {
+		return m, err
+	}
+	return items[0], nil
+}
+
+// insertMessage is CreateMessage within tx, for callers that must commit the
+// message together with their own writes.
+func insertMessage(ctx context.Context, tx *sql.Tx, in NewMessage) (models.Message, error) {
+	if in.ParentID != nil {
+		var ok bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM messages
+				WHERE id = $1 AND room_id = $2 AND parent_id IS NULL AND deleted_at IS NULL
+			)
+		`, *in.ParentID, in.RoomID).Scan(&ok); err != nil {
+			return models.Message{}, err
+		}
+		if !ok {
+			return models.Message{}, ErrInvalidParent
+		}
+	}
+	if in.ReplyToID != nil {
+		var ok bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM messages
+				WHERE id = $1 AND room_id = $2 AND deleted_at IS NULL
+			)
+		`, *in.ReplyToID, in.RoomID).Scan(&ok); err != nil {
+			return models.Message{}, err
+		}
+		if !ok {
+			return models.Message{}, ErrInvalidReplyTo
+		}
+	}
+
+	m, err := scanMessage(tx.QueryRowContext(ctx, `
+		INSERT INTO messages (room_id, user_id, content, parent_id, reply_to_id, expires_at)
+		SELECT $1::bigint, $2::bigint, $3, $4::bigint, $5::bigint,
+		       CASE WHEN $6::int > 0 THEN NOW() + $6::int * INTERVAL '1 second' END
+		WHERE `+roomVisible("$1", "$2")+`
+		RETURNING `+messageColumns, in.RoomID, in.UserID, in.Content, in.ParentID, in.ReplyToID, int(in.TTL/time.Second)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, ErrNotFound
+	}
+	if err != nil {
+		return m, err
+	}
+	if len(in.AttachmentIDs) > 0 {
+		if err := linkAttachments(ctx, tx, &m, in.AttachmentIDs); err != nil {
+			return models.Message{}, err
+		}
+	}
+	if in.Poll != nil {
+		if err := insertPoll(ctx, tx, &m, *in.Poll); err != nil {
+			return models.Message{}, err
+		}
+	}
+	return m, nil
+}
+
+// EditMessage replaces a message's content and keeps the previous one in
+// message_revisions. Only the author or a room moderator may edit, and only
+// within the edit window. Expired messages not yet swept are ErrNotFound, so
+// their content never reaches a revision. previous is the content before the
+// edit.
+func (s *Store) EditMessage(ctx context.Context, roomID, msgID, editorID int64, content string) (m models.Message, previous string, err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Message{}, "", err
+	}
+	defer tx.Rollback()
+
+	m, err = scanMessage(tx.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE id = $1 AND room_id = $2 AND deleted_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND `+roomVisible("$2", "$3")+`
+		FOR UPDATE
+	`, msgID, roomID, editorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Message{}, "", ErrNotFound
+	}
+	if err != nil {
+		return models.Message{}, "", err
+	}
+	if err := authorOrModerator(ctx, tx, m, editorID); err != nil {
+		return models.Message{}, "", err
+	}
+	window := s.EditWindow
+	if window <= 0 {
+		window = DefaultEditWindow
+	}
+	if time.Since(m.CreatedAt) > window {
+		return models.Message{}, "", ErrEditWindowClosed
+	}
+	previous = m.Content
+	if content == previous {
+		return m, previous, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO message_revisions (message_id, content, edited_by) VALUES ($1, $2, $3)
+	`, m.ID, m.Content, editorID); err != nil {
+		return models.Message{}, "", err
+	}
+	m, err = scanMessage(tx.QueryRowContext(ctx, `
+		UPDATE messages SET content = $2, edited_at = NOW(), embeds = NULL
+		WHERE id = $1
+		RETURNING `+messageColumns, m.ID, content))
+	if err != nil {
+		return models.Message{}, "", err
+	}
+	return m, previous, tx.Commit()
+}
+
+// DeleteMessage soft-deletes a message (author or room moderator) and
+// returns its tombstone. Expired ones are ErrNotFound; the sweeper owns them.
+func (s *Store) DeleteMessage(ctx context.Context, roomID, msgID, userID int64) (models.Message, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	m, err := scanMessage(tx.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE id = $1 AND room_id = $2 AND deleted_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND `+roomVisible("$2", "$3")+`
+		FOR UPDATE
+	`, msgID, roomID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Message{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Message{}, err
+	}
+	if err := authorOrModerator(ctx, tx, m, userID); err != nil {
+		return models.Message{}, err
+	}
+
+	m, err = scanMessage(tx.QueryRowContext(ctx, `
+		UPDATE messages SET deleted_at = NOW()
+		WHERE id = $1
+		RETURNING `+messageColumns, m.ID))
+	if err != nil {
+		return models.Message{}, err
+	}
+	return m, tx.Commit()
+}
+
+// ExpireMessages soft-deletes up to batch ephemeral messages whose expiry is
+// before now and returns their tombstones. Their content, embeds and edit
+// history go in the same statement; their attachments go with the
+// transaction, files first through remove. SKIP LOCKED lets sweepers on
+// several instances run side by side without double-deleting.
+func (s *Store) ExpireMessages(ctx context.Context, now time.Time, batch int, remove func(keys []string) error) ([]models.Message, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH expired AS (
+			UPDATE messages SET deleted_at = NOW(), content = '', embeds = NULL
+			WHERE id IN (
+				SELECT id FROM messages
+				WHERE expires_at <= $1 AND deleted_at IS NULL
+				ORDER BY expires_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING `+messageColumns+`
+		), revisions AS (
+			DELETE FROM message_revisions WHERE message_id IN (SELECT id FROM expired)
+		)
+		SELECT `+messageColumns+` FROM expired
+	`, now, batch)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out []models.Message
+		ids []int64
+	)
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, m)
+		ids = append(ids, m.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	if _, err := deleteAttachments(ctx, tx, remove, `
+		SELECT id, storage_key, thumb_key FROM attachments
+		WHERE message_id = ANY($1)
+		FOR UPDATE
+	`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit()
+}
+
+// PurgeDeletedMessages hard-deletes up to batch tombstones deleted before cutoff.
+// Replies cascade with their root, so a root stays while any reply is live.
+// Attachment rows would cascade too, so tombstones wait until
+// CleanupAttachments has deleted their files.
+func (s *Store) PurgeDeletedMessages(ctx context.Context, cutoff time.Time, batch int) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM messages
+		WHERE id IN (
+			SELECT id FROM messages
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			  AND NOT EXISTS (
+				SELECT 1 FROM messages c
+				WHERE c.parent_id = messages.id AND c.deleted_at IS NULL
+			  )
+			  AND NOT EXISTS (
+				SELECT 1 FROM attachments a
+				JOIN messages am ON am.id = a.message_id
+				WHERE am.id = messages.id OR am.parent_id = messages.id
+			  )
+			LIMIT $2
 		)
-	`, roomID, ts, id).Scan(&has)
-	return has, err
-}
-
-func (s *Store) CreateMessage(ctx context.Context, roomID, userID int64, content string) (models.Message, error) {
-	var m models.Message
-	err := s.DB.QueryRowContext(ctx, `
-		INSERT INTO messages (room_id, user_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, room_id, user_id, content, created_at
-	`, roomID, userID, content).
-		Scan(&m.ID, &m.RoomID, &m.UserID, &m.Content, &m.CreatedAt)
+	`, cutoff, batch)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func authorOrModerator(ctx context.Context, tx *sql.Tx, m models.Message, userID int64) error {
+	if m.UserID == userID {
+		return nil
+	}
+	return requireModerator(ctx, tx, m.RoomID, userID)
+}
+
+// requireModerator returns ErrForbidden unless the user moderates the room.
+func requireModerator(ctx context.Context, tx *sql.Tx, roomID, userID int64) error {
+	var role string
+	err := tx.QueryRowContext(ctx, `
+		SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2
+	`, roomID, userID).Scan(&role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if !models.CanModerate(role) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// SetMessageEmbeds stores link previews computed from content. It returns
+// ErrNotFound when the message was deleted or edited meanwhile, so stale
+// previews never land.
+func (s *Store) SetMessageEmbeds(ctx context.Context, msgID int64, content string, embeds []models.Embed) (models.Message, error) {
+	b, err := json.Marshal(embeds)
+	if err != nil {
+		return models.Message{}, err
+	}
+	m, err := scanMessage(s.DB.QueryRowContext(ctx, `
+		UPDATE messages SET embeds = $3
+		WHERE id = $1 AND content = $2 AND deleted_at IS NULL
+		RETURNING `+messageColumns, msgID, content, b))
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, ErrNotFound
+	}
 	return m, err
 }
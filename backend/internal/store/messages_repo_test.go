@@ -0,0 +1,330 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return NewFormDB(db), mock
+}
+
+var messageCols = []string{"id", "room_id", "user_id", "parent_id", "reply_to_id", "content", "created_at", "edited_at", "deleted_at", "embeds", "expires_at"}
+
+func messageRow(id, roomID, userID int64, content string, createdAt time.Time) *sqlmock.Rows {
+	return sqlmock.NewRows(messageCols).
+		AddRow(id, roomID, userID, nil, nil, content, createdAt, nil, nil, nil, nil)
+}
+
+func TestEditMessage_WindowClosed(t *testing.T) {
+	s, mock := newMockStore(t)
+	s.EditWindow = time.Minute
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WithArgs(int64(10), int64(1), int64(7)).
+		WillReturnRows(messageRow(10, 1, 7, "old", time.Now().Add(-time.Hour)))
+	mock.ExpectRollback()
+
+	_, _, err := s.EditMessage(context.Background(), 1, 10, 7, "new")
+	if !errors.Is(err, ErrEditWindowClosed) {
+		t.Fatalf("want ErrEditWindowClosed, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestEditMessage_ExpiredIsGone(t *testing.T) {
+	s, mock := newMockStore(t)
+
+	// past expires_at but not swept yet: the lock query finds nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM messages\s+WHERE id = \$1 AND room_id = \$2 AND deleted_at IS NULL\s+AND \(expires_at IS NULL OR expires_at > NOW\(\)\)(.+)FOR UPDATE`).
+		WithArgs(int64(10), int64(1), int64(7)).
+		WillReturnRows(sqlmock.NewRows(messageCols))
+	mock.ExpectRollback()
+
+	if _, _, err := s.EditMessage(context.Background(), 1, 10, 7, "new"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestEditMessage_NotAuthorNorModerator(t *testing.T) {
+	s, mock := newMockStore(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WillReturnRows(messageRow(10, 1, 7, "old", time.Now()))
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs(int64(1), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("member"))
+	mock.ExpectRollback()
+
+	_, _, err := s.EditMessage(context.Background(), 1, 10, 8, "new")
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("want ErrForbidden, got %v", err)
+	}
+}
+
+func TestEditMessage_KeepsRevision(t *testing.T) {
+	s, mock := newMockStore(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WillReturnRows(messageRow(10, 1, 7, "old", time.Now()))
+	mock.ExpectExec(`INSERT INTO message_revisions`).
+		WithArgs(int64(10), "old", int64(7)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`UPDATE messages SET content`).
+		WithArgs(int64(10), "new").
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, nil, nil, "new", time.Now(), time.Now(), nil, nil, nil))
+	mock.ExpectCommit()
+
+	m, previous, err := s.EditMessage(context.Background(), 1, 10, 7, "new")
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if m.Content != "new" || m.EditedAt == nil || previous != "old" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListMessagesAround_NewestFirstWithTarget(t *testing.T) {
+	s, mock := newMockStore(t)
+	parentID := int64(5)
+	ts := time.Now()
+	noReactions := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"message_id", "emoji", "count", "me"})
+	}
+
+	mock.ExpectQuery(`SELECT created_at FROM messages`).
+		WithArgs(int64(10), int64(1), &parentID, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(ts))
+	mock.ExpectQuery(`SELECT (.+) FROM messages (.+) > \(\$4, \$5\) ORDER BY created_at ASC, id ASC`).
+		WithArgs(int64(1), int64(7), int64(5), ts, int64(10), 3).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(11, 1, 7, 5, nil, "b", ts.Add(time.Second), nil, nil, nil, nil).
+			AddRow(12, 1, 7, 5, nil, "c", ts.Add(2*time.Second), nil, nil, nil, nil))
+	mock.ExpectQuery(`FROM message_reactions`).WillReturnRows(noReactions())
+	mock.ExpectQuery(`FROM attachments`).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery(`FROM polls`).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery(`SELECT (.+) FROM messages (.+) < \(\$4, \$5\) ORDER BY created_at DESC, id DESC`).
+		WithArgs(int64(1), int64(7), int64(5), ts, int64(11), 4).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, 5, nil, "target", ts, nil, nil, nil, nil).
+			AddRow(9, 1, 7, 5, nil, "a", ts.Add(-time.Second), nil, nil, nil, nil).
+			AddRow(8, 1, 7, 5, nil, "z", ts.Add(-2*time.Second), nil, nil, nil, nil).
+			AddRow(7, 1, 7, 5, nil, "y", ts.Add(-3*time.Second), nil, nil, nil, nil))
+	mock.ExpectQuery(`FROM message_reactions`).WillReturnRows(noReactions())
+	mock.ExpectQuery(`FROM attachments`).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery(`FROM polls`).WillReturnRows(sqlmock.NewRows(nil))
+
+	items, hasOlder, hasNewer, err := s.ListMessagesAround(context.Background(), 1, 7, &parentID, 10, 2)
+	if err != nil {
+		t.Fatalf("around: %v", err)
+	}
+	if !hasOlder || hasNewer {
+		t.Fatalf("hasOlder=%v hasNewer=%v, want true/false", hasOlder, hasNewer)
+	}
+	var got []int64
+	for _, m := range items {
+		got = append(got, m.ID)
+	}
+	if want := []int64{12, 11, 10, 9, 8}; !slices.Equal(got, want) {
+		t.Fatalf("ids = %v, want %v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestExpireMessages_Tombstones(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	// content, embeds and edit history go with the expiry itself
+	mock.ExpectQuery(`UPDATE messages SET deleted_at = NOW\(\), content = '', embeds = NULL(.+)expires_at <= \$1(.+)FOR UPDATE SKIP LOCKED(.+)DELETE FROM message_revisions WHERE message_id IN \(SELECT id FROM expired\)`).
+		WithArgs(now, 500).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, nil, nil, "", now.Add(-time.Minute), nil, now, nil, now.Add(-time.Second)).
+			AddRow(11, 1, 7, nil, nil, "", now.Add(-time.Minute), nil, now, nil, now.Add(-time.Second)))
+	mock.ExpectQuery(`SELECT id, storage_key, thumb_key FROM attachments\s+WHERE message_id = ANY\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_key", "thumb_key"}).
+			AddRow(3, "rooms/1/a", "rooms/1/a.thumb.jpg"))
+	mock.ExpectExec(`DELETE FROM attachments WHERE id = ANY\(\$1\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var removed []string
+	ms, err := s.ExpireMessages(context.Background(), now, 500, func(keys []string) error {
+		removed = keys
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || len(removed) != 2 {
+		t.Fatalf("expired %d messages, removed files %v", len(ms), removed)
+	}
+	for _, m := range ms {
+		if !m.Deleted || m.Content != "" || m.HTML != "" || m.ExpiresAt == nil {
+			t.Fatalf("expired message not a tombstone: %+v", m)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestExpireMessages_KeepsMessagesWhenFilesRemain(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`WITH expired AS`).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, nil, nil, "", now.Add(-time.Minute), nil, now, nil, now.Add(-time.Second)))
+	mock.ExpectQuery(`FROM attachments`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "storage_key", "thumb_key"}).AddRow(3, "rooms/1/a", nil))
+	mock.ExpectRollback()
+
+	storageDown := errors.New("storage down")
+	if _, err := s.ExpireMessages(context.Background(), now, 500, func([]string) error { return storageDown }); !errors.Is(err, storageDown) {
+		t.Fatalf("want storage error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestPurgeDeletedMessages_KeepsRootsWithLiveReplies(t *testing.T) {
+	s, mock := newMockStore(t)
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	// deleting a root cascades to its replies, so roots with live ones are skipped
+	mock.ExpectExec(`DELETE FROM messages(.+)deleted_at < \$1(.+)NOT EXISTS \(\s*SELECT 1 FROM messages c\s*WHERE c.parent_id = messages.id AND c.deleted_at IS NULL`).
+		WithArgs(cutoff, 100).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := s.PurgeDeletedMessages(context.Background(), cutoff, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("purged %d rows, want 0", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDeleteMessage_AuthorGetsTombstone(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages\s+WHERE id = \$1 AND room_id = \$2 AND deleted_at IS NULL\s+AND \(expires_at IS NULL OR expires_at > NOW\(\)\)(.+)FOR UPDATE`).
+		WithArgs(int64(10), int64(1), int64(7)).
+		WillReturnRows(messageRow(10, 1, 7, "bye", now))
+	mock.ExpectQuery(`UPDATE messages SET deleted_at = NOW\(\)\s+WHERE id = \$1`).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, nil, nil, "bye", now, nil, now, []byte(`[{"url":"https://example.com"}]`), nil))
+	mock.ExpectCommit()
+
+	m, err := s.DeleteMessage(context.Background(), 1, 10, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Deleted || m.Content != "" || m.HTML != "" || m.Embeds != nil || m.ID != 10 || m.UserID != 7 {
+		t.Fatalf("not a tombstone: %+v", m)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDeleteMessage_ModeratorDeletesOthers(t *testing.T) {
+	s, mock := newMockStore(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WithArgs(int64(10), int64(1), int64(8)).
+		WillReturnRows(messageRow(10, 1, 7, "spam", now))
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs(int64(1), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("moderator"))
+	mock.ExpectQuery(`UPDATE messages SET deleted_at`).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(10, 1, 7, nil, nil, "spam", now, nil, now, nil, nil))
+	mock.ExpectCommit()
+
+	if _, err := s.DeleteMessage(context.Background(), 1, 10, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDeleteMessage_Rejections(t *testing.T) {
+	s, mock := newMockStore(t)
+	// someone else's message, caller is a plain member
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WillReturnRows(messageRow(10, 1, 7, "mine", time.Now()))
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs(int64(1), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("member"))
+	mock.ExpectRollback()
+	// already deleted, or in a room the caller can't see
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WillReturnRows(sqlmock.NewRows(messageCols))
+	mock.ExpectRollback()
+
+	if _, err := s.DeleteMessage(context.Background(), 1, 10, 8); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("want ErrForbidden, got %v", err)
+	}
+	if _, err := s.DeleteMessage(context.Background(), 1, 10, 8); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestPurgeDeletedMessages_CutoffAndBatch(t *testing.T) {
+	s, mock := newMockStore(t)
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	mock.ExpectExec(`DELETE FROM messages\s+WHERE id IN \(\s*SELECT id FROM messages\s+WHERE deleted_at IS NOT NULL AND deleted_at < \$1(.+)LIMIT \$2`).
+		WithArgs(cutoff, 500).
+		WillReturnResult(sqlmock.NewResult(0, 500))
+
+	n, err := s.PurgeDeletedMessages(context.Background(), cutoff, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 500 {
+		t.Fatalf("purged %d, want 500", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrTooManyPins = errors.New("too many pins")
+
+// MaxPinsPerRoom caps live pinned messages per room.
+const MaxPinsPerRoom = 50
+
+// PinMessage pins a live message; moderators only. Pinning a pinned message
+// is a no-op that returns the existing pin with added=false.
+func (s *Store) PinMessage(ctx context.Context, roomID, msgID, userID int64) (models.Pin, bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Pin{}, false, err
+	}
+	defer tx.Rollback()
+
+	// Serializes pins per room so concurrent requests can't pass the cap
+	// together. NO KEY UPDATE leaves message inserts (FK KEY SHARE) unblocked.
+	var locked int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM rooms WHERE id = $1 FOR NO KEY UPDATE`, roomID).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Pin{}, false, ErrNotFound
+	}
+	if err != nil {
+		return models.Pin{}, false, err
+	}
+
+	m, err := scanMessage(tx.QueryRowContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE id = $1 AND room_id = $2 AND deleted_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+		  AND `+roomVisible("$2", "$3"),
+		msgID, roomID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Pin{}, false, ErrNotFound
+	}
+	if err != nil {
+		return models.Pin{}, false, err
+	}
+	if err := requireModerator(ctx, tx, roomID, userID); err != nil {
+		return models.Pin{}, false, err
+	}
+
+	p := models.Pin{Message: m}
+	var (
+		by       sql.NullInt64
+		username sql.NullString
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.pinned_by, u.username, p.pinned_at
+		FROM pinned_messages p
+		LEFT JOIN users u ON u.id = p.pinned_by
+		WHERE p.message_id = $1
+	`, msgID).Scan(&by, &username, &p.PinnedAt)
+	if err == nil {
+		if by.Valid {
+			p.PinnedBy = &by.Int64
+		}
+		p.PinnedByUsername = username.String
+		return p, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.Pin{}, false, err
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.room_id = $1 AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+	`, roomID).Scan(&n); err != nil {
+		return models.Pin{}, false, err
+	}
+	if n >= MaxPinsPerRoom {
+		return models.Pin{}, false, ErrTooManyPins
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO pinned_messages (message_id, room_id, pinned_by) VALUES ($1, $2, $3)
+		RETURNING pinned_at, (SELECT username FROM users WHERE id = $3)
+	`, msgID, roomID, userID).Scan(&p.PinnedAt, &username); err != nil {
+		return models.Pin{}, false, err
+	}
+	p.PinnedBy = &userID
+	p.PinnedByUsername = username.String
+	return p, true, tx.Commit()
+}
+
+// UnpinMessage removes a pin; moderators only. removed=false if the message
+// wasn't pinned.
+func (s *Store) UnpinMessage(ctx context.Context, roomID, msgID, userID int64) (bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if err := requireModerator(ctx, tx, roomID, userID); err != nil {
+		return false, err
+	}
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM pinned_messages WHERE message_id = $1 AND room_id = $2
+	`, msgID, roomID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, tx.Commit()
+}
+
+// ListPins returns the room's live pinned messages, most recently pinned
+// first. The list is bounded by MaxPinsPerRoom, so it isn't paged.
+func (s *Store) ListPins(ctx context.Context, roomID, viewerID int64) ([]models.Pin, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+mMessageColumns+`,
+		       p.pinned_by, u.username, p.pinned_at
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id
+		LEFT JOIN users u ON u.id = p.pinned_by
+		WHERE p.room_id = $1 AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		  AND `+roomVisible("$1", "$2")+`
+		ORDER BY p.pinned_at DESC, p.message_id DESC
+	`, roomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []models.Pin{}
+	for rows.Next() {
+		var (
+			p        models.Pin
+			by       sql.NullInt64
+			username sql.NullString
+		)
+		m, err := scanMessage(trailingRow{rows, []any{&by, &username, &p.PinnedAt}})
+		if err != nil {
+			return nil, err
+		}
+		p.Message = m
+		if by.Valid {
+			p.PinnedBy = &by.Int64
+		}
+		p.PinnedByUsername = username.String
+		pins = append(pins, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]models.Message, len(pins))
+	for i, p := range pins {
+		items[i] = p.Message
+	}
+	if err := s.hydrate(ctx, viewerID, items); err != nil {
+		return nil, err
+	}
+	for i := range pins {
+		pins[i].Message = items[i]
+	}
+	return pins, nil
+}
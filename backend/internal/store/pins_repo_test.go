@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectPinTarget(mock sqlmock.Sqlmock, role string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM rooms WHERE id = \$1 FOR NO KEY UPDATE`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT (.+) FROM messages`).
+		WithArgs(int64(10), int64(1), int64(8)).
+		WillReturnRows(messageRow(10, 1, 7, "announcement", time.Now()))
+	mock.ExpectQuery(`SELECT role FROM room_members`).
+		WithArgs(int64(1), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(role))
+}
+
+func TestPinMessage_ModeratorsOnly(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectPinTarget(mock, "member")
+	mock.ExpectRollback()
+
+	if _, _, err := s.PinMessage(context.Background(), 1, 10, 8); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("want ErrForbidden, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestPinMessage_RoomLimit(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectPinTarget(mock, "moderator")
+	mock.ExpectQuery(`SELECT p.pinned_by, u.username, p.pinned_at`).
+		WillReturnRows(sqlmock.NewRows([]string{"pinned_by", "username", "pinned_at"}))
+	mock.ExpectQuery(`SELECT COUNT\(\*\)`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(MaxPinsPerRoom))
+	mock.ExpectRollback()
+
+	if _, _, err := s.PinMessage(context.Background(), 1, 10, 8); !errors.Is(err, ErrTooManyPins) {
+		t.Fatalf("want ErrTooManyPins, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestPinMessage_AlreadyPinned(t *testing.T) {
+	s, mock := newMockStore(t)
+	at := time.Now().Add(-time.Hour)
+	expectPinTarget(mock, "owner")
+	mock.ExpectQuery(`SELECT p.pinned_by, u.username, p.pinned_at`).
+		WithArgs(int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"pinned_by", "username", "pinned_at"}).AddRow(3, "alice", at))
+	mock.ExpectRollback()
+
+	p, added, err := s.PinMessage(context.Background(), 1, 10, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added || p.PinnedBy == nil || *p.PinnedBy != 3 || p.PinnedByUsername != "alice" || !p.PinnedAt.Equal(at) {
+		t.Fatalf("got added=%v pin=%+v", added, p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestListPins_SkipsDeletedAndExpired(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM pinned_messages p(.+)WHERE p.room_id = \$1 AND m.deleted_at IS NULL\s+AND \(m.expires_at IS NULL OR m.expires_at > NOW\(\)\)`).
+		WithArgs(int64(1), int64(7)).
+		WillReturnRows(sqlmock.NewRows(append(append([]string{}, messageCols...), "pinned_by", "username", "pinned_at")))
+
+	pins, err := s.ListPins(context.Background(), 1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatalf("want no pins, got %+v", pins)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed    = errors.New("poll closed")
+	ErrInvalidOption = errors.New("invalid poll option")
+)
+
+// NewPoll makes the message being created a poll; its content is the question.
+type NewPoll struct {
+	Options   []string
+	Multiple  bool
+	Anonymous bool
+	ClosesAt  *time.Time
+}
+
+// queryer is what loadPolls needs from *sql.DB or *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func insertPoll(ctx context.Context, tx *sql.Tx, m *models.Message, p NewPoll) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO polls (message_id, multiple, anonymous, closes_at) VALUES ($1, $2, $3, $4)
+	`, m.ID, p.Multiple, p.Anonymous, p.ClosesAt); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO poll_options (message_id, position, text)
+		SELECT $1::bigint, t.pos, t.text
+		FROM unnest($2::text[]) WITH ORDINALITY AS t(text, pos)
+	`, m.ID, pq.Array(p.Options)); err != nil {
+		return err
+	}
+	polls, err := loadPolls(ctx, tx, []int64{m.ID}, m.UserID)
+	if err != nil {
+		return err
+	}
+	m.Poll = polls[m.ID]
+	return nil
+}
+
+// loadPolls returns the polls among msgIDs with tallies; Me is relative to
+// viewerID.
+func loadPolls(ctx context.Context, q queryer, msgIDs []int64, viewerID int64) (map[int64]*models.Poll, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT p.message_id, p.multiple, p.anonymous, p.closes_at,
+		       (SELECT COUNT(DISTINCT v.user_id) FROM poll_votes v WHERE v.message_id = p.message_id)
+		FROM polls p
+		WHERE p.message_id = ANY($1)
+	`, pq.Array(msgIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := make(map[int64]*models.Poll)
+	now := time.Now()
+	for rows.Next() {
+		var (
+			p        models.Poll
+			closesAt sql.NullTime
+		)
+		if err := rows.Scan(&p.MessageID, &p.Multiple, &p.Anonymous, &closesAt, &p.TotalVoters); err != nil {
+			return nil, err
+		}
+		if closesAt.Valid {
+			p.ClosesAt = &closesAt.Time
+			p.Closed = !closesAt.Time.After(now)
+		}
+		polls[p.MessageID] = &p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(polls) == 0 {
+		return polls, nil
+	}
+
+	orows, err := q.QueryContext(ctx, `
+		SELECT o.message_id, o.id, o.text, COUNT(v.user_id), COALESCE(BOOL_OR(v.user_id = $2), FALSE),
+		       COALESCE(ARRAY_AGG(v.user_id ORDER BY v.created_at) FILTER (WHERE v.user_id IS NOT NULL), '{}')
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.message_id = ANY($1)
+		GROUP BY o.message_id, o.id
+		ORDER BY o.message_id, o.position
+	`, pq.Array(msgIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer orows.Close()
+
+	for orows.Next() {
+		var (
+			msgID  int64
+			o      models.PollOption
+			voters pq.Int64Array
+		)
+		if err := orows.Scan(&msgID, &o.ID, &o.Text, &o.Votes, &o.Me, &voters); err != nil {
+			return nil, err
+		}
+		p, ok := polls[msgID]
+		if !ok {
+			continue
+		}
+		if !p.Anonymous {
+			o.Voters = voters
+		}
+		p.Options = append(p.Options, o)
+	}
+	return polls, orows.Err()
+}
+
+// attachPolls fills Poll on poll messages. Tombstones keep none.
+func (s *Store) attachPolls(ctx context.Context, viewerID int64, items []models.Message) error {
+	ids := make([]int64, 0, len(items))
+	for _, m := range items {
+		if !m.Deleted {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	polls, err := loadPolls(ctx, s.DB, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].Poll = polls[items[i].ID]
+	}
+	return nil
+}
+
+// Vote adds (add=true) or removes the user's vote on an option of a live poll
+// in the room. Anyone who can see the room votes, each option at most once; on a
+// single-choice poll a new vote replaces the previous one. It returns the
+// poll as the voter sees it and whether anything changed.
+func (s *Store) Vote(ctx context.Context, roomID, msgID, optionID, userID int64, add bool) (models.Poll, bool, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Poll{}, false, err
+	}
+	defer tx.Rollback()
+
+	// the poll row lock serializes votes, so single choice holds under races
+	var (
+		multiple bool
+		closesAt sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT p.multiple, p.closes_at
+		FROM polls p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.message_id = $1 AND m.room_id = $2 AND m.deleted_at IS NULL
+		  AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		FOR UPDATE OF p
+	`, msgID, roomID).Scan(&multiple, &closesAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Poll{}, false, ErrNotFound
+	}
+	if err != nil {
+		return models.Poll{}, false, err
+	}
+
+	// public rooms have no member rows, so voting follows room visibility
+	var visible, validOption bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			`+roomVisible("$1", "$2")+`,
+			EXISTS (SELECT 1 FROM poll_options WHERE id = $3 AND message_id = $4)
+	`, roomID, userID, optionID, msgID).Scan(&visible, &validOption); err != nil {
+		return models.Poll{}, false, err
+	}
+	if !visible {
+		return models.Poll{}, false, ErrForbidden
+	}
+	if closesAt.Valid && !closesAt.Time.After(time.Now()) {
+		return models.Poll{}, false, ErrPollClosed
+	}
+	if !validOption {
+		return models.Poll{}, false, ErrInvalidOption
+	}
+
+	var changed int64
+	if add {
+		if !multiple {
+			res, err := tx.ExecContext(ctx, `
+				DELETE FROM poll_votes WHERE message_id = $1 AND user_id = $2 AND option_id <> $3
+			`, msgID, userID, optionID)
+			if err != nil {
+				return models.Poll{}, false, err
+			}
+			n, _ := res.RowsAffected()
+			changed += n
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO poll_votes (option_id, message_id, user_id) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, optionID, msgID, userID)
+		if err != nil {
+			return models.Poll{}, false, err
+		}
+		n, _ := res.RowsAffected()
+		changed += n
+	} else {
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM poll_votes WHERE option_id = $1 AND user_id = $2
+		`, optionID, userID)
+		if err != nil {
+			return models.Poll{}, false, err
+		}
+		changed, _ = res.RowsAffected()
+	}
+
+	polls, err := loadPolls(ctx, tx, []int64{msgID}, userID)
+	if err != nil {
+		return models.Poll{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Poll{}, false, err
+	}
+	return *polls[msgID], changed > 0, nil
+}
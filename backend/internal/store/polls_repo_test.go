@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func expectVoteChecks(mock sqlmock.Sqlmock, multiple bool, closesAt any, visible bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT p.multiple, p.closes_at(.+)FOR UPDATE OF p`).
+		WithArgs(int64(10), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"multiple", "closes_at"}).AddRow(multiple, closesAt))
+	mock.ExpectQuery(`FROM rooms vr(.+)vr.is_public(.+)FROM poll_options`).
+		WithArgs(int64(1), int64(7), int64(3), int64(10)).
+		WillReturnRows(sqlmock.NewRows([]string{"visible", "option"}).AddRow(visible, true))
+}
+
+func expectPollLoad(mock sqlmock.Sqlmock, anonymous bool) {
+	mock.ExpectQuery(`FROM polls p`).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "multiple", "anonymous", "closes_at", "count"}).
+			AddRow(10, false, anonymous, nil, 2))
+	mock.ExpectQuery(`FROM poll_options o`).
+		WithArgs(sqlmock.AnyArg(), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "id", "text", "count", "me", "voters"}).
+			AddRow(10, 3, "yes", 2, true, pq.Int64Array{7, 8}).
+			AddRow(10, 4, "no", 0, false, pq.Int64Array{}))
+}
+
+func TestVote_SingleChoiceReplacesPrevious(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectVoteChecks(mock, false, nil, true)
+	mock.ExpectExec(`DELETE FROM poll_votes WHERE message_id = \$1 AND user_id = \$2 AND option_id <> \$3`).
+		WithArgs(int64(10), int64(7), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO poll_votes`).
+		WithArgs(int64(3), int64(10), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectPollLoad(mock, false)
+	mock.ExpectCommit()
+
+	p, changed, err := s.Vote(context.Background(), 1, 10, 3, 7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || len(p.Options) != 2 || !p.Options[0].Me || p.Options[0].Votes != 2 || len(p.Options[0].Voters) != 2 {
+		t.Fatalf("got changed=%v poll=%+v", changed, p)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestVote_AnonymousHidesVoters(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectVoteChecks(mock, true, nil, true)
+	mock.ExpectExec(`INSERT INTO poll_votes`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectPollLoad(mock, true)
+	mock.ExpectCommit()
+
+	p, changed, err := s.Vote(context.Background(), 1, 10, 3, 7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || p.Options[0].Voters != nil || p.Options[0].Votes != 2 {
+		t.Fatalf("got changed=%v poll=%+v", changed, p)
+	}
+}
+
+func TestVote_Rejections(t *testing.T) {
+	cases := []struct {
+		name     string
+		closesAt any
+		visible  bool
+		want     error
+	}{
+		{"room not visible", nil, false, ErrForbidden},
+		{"closed", time.Now().Add(-time.Minute), true, ErrPollClosed},
+	}
+	for _, tc := range cases {
+		s, mock := newMockStore(t)
+		expectVoteChecks(mock, false, tc.closesAt, tc.visible)
+		mock.ExpectRollback()
+
+		if _, _, err := s.Vote(context.Background(), 1, 10, 3, 7, true); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: want %v, got %v", tc.name, tc.want, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: unmet sql expectations: %v", tc.name, err)
+		}
+	}
+}
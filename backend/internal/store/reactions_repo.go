@@ -0,0 +1,172 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidEmoji     = errors.New("invalid emoji")
+	ErrTooManyReactions = errors.New("too many reactions")
+)
+
+const (
+	maxReactionsPerUser = 20 // distinct emojis one user may put on one message
+	maxEmojiRunes       = 32
+)
+
+// shortcodeRe matches custom emoji names such as :party_parrot:.
+var shortcodeRe = regexp.MustCompile(`^:[a-z0-9_+-]{1,30}:$`)
+
+// validEmoji accepts a shortcode or a single emoji sequence: pictographs
+// joined by ZWJ, with variation selectors, skin tones, keycaps and flag tags.
+func validEmoji(e string) bool {
+	if e == "" || len(e) > 64 || utf8.RuneCountInString(e) > maxEmojiRunes || !utf8.ValidString(e) {
+		return false
+	}
+	if shortcodeRe.MatchString(e) {
+		return true
+	}
+	emoji := false
+	for _, r := range e {
+		switch {
+		case isPictograph(r), r == 0x20E3: // keycap makes "1" + U+20E3 an emoji
+			emoji = true
+		case r == 0x200D, r == 0xFE0E, r == 0xFE0F, r >= 0xE0020 && r <= 0xE007F:
+		case r >= '0' && r <= '9', r == '#', r == '*':
+		default:
+			return false
+		}
+	}
+	return emoji
+}
+
+// isPictograph covers the Unicode blocks emoji are drawn from.
+func isPictograph(r rune) bool {
+	switch {
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139,
+		r == 0x24C2, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	case r >= 0x2194 && r <= 0x21AA, r >= 0x231A && r <= 0x23FF, r >= 0x25AA && r <= 0x25FE,
+		r >= 0x2600 && r <= 0x27BF, r >= 0x2934 && r <= 0x2935, r >= 0x2B05 && r <= 0x2B55,
+		r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	}
+	return false
+}
+
+// AddReaction records the user's emoji on a live message. Adding the same
+// emoji twice is a no-op and reports added=false.
+func (s *Store) AddReaction(ctx context.Context, roomID, msgID, userID int64, emoji string) (bool, error) {
+	if !validEmoji(emoji) {
+		return false, ErrInvalidEmoji
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// the message row lock serializes concurrent adds, so the cap holds
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM messages
+		WHERE id = $1 AND room_id = $2 AND deleted_at IS NULL
+		  AND `+roomVisible("$2", "$3")+`
+		FOR NO KEY UPDATE
+	`, msgID, roomID, userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	var mine int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM message_reactions WHERE message_id = $1 AND user_id = $2
+	`, msgID, userID).Scan(&mine); err != nil {
+		return false, err
+	}
+	if mine >= maxReactionsPerUser {
+		return false, ErrTooManyReactions
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO message_reactions (message_id, user_id, emoji) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, msgID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, tx.Commit()
+}
+
+// RemoveReaction deletes the user's emoji; removed=false if it wasn't there.
+func (s *Store) RemoveReaction(ctx context.Context, roomID, msgID, userID int64, emoji string) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM message_reactions r
+		USING messages m
+		WHERE r.message_id = $1 AND r.user_id = $3 AND r.emoji = $4
+		  AND m.id = r.message_id AND m.room_id = $2
+	`, msgID, roomID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// attachReactions fills Reactions on items with one aggregate query.
+// Tombstones keep no reactions.
+func (s *Store) attachReactions(ctx context.Context, viewerID int64, items []models.Message) error {
+	if len(items) == 0 {
+		return nil
+	}
+	idx := make(map[int64]int, len(items))
+	ids := make([]int64, 0, len(items))
+	for i, m := range items {
+		if m.Deleted {
+			continue
+		}
+		idx[m.ID] = i
+		ids = append(ids, m.ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $2)
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at)
+	`, pq.Array(ids), viewerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			msgID int64
+			r     models.Reaction
+			me    sql.NullBool
+		)
+		if err := rows.Scan(&msgID, &r.Emoji, &r.Count, &me); err != nil {
+			return err
+		}
+		r.Me = me.Bool
+		if i, ok := idx[msgID]; ok {
+			items[i].Reactions = append(items[i].Reactions, r)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidEmoji(t *testing.T) {
+	cases := []struct {
+		in string
+		ok bool
+	}{
+		{"👍", true},
+		{"👨‍👩‍👧", true},
+		{"👍🏽", true},
+		{"❤️", true},
+		{"1️⃣", true},
+		{"🇫🇷", true},
+		{"🏴󠁧󠁢󠁳󠁣󠁴󠁿", true},
+		{":party_parrot:", true},
+		{"", false},
+		{"a b", false},
+		{"👍\n", false},
+		{"\x00", false},
+		{"lol", false},
+		{"<b>", false},
+		{"1", false},
+		{"👍a", false},
+		{":Bad Name:", false},
+	}
+	for _, c := range cases {
+		if validEmoji(c.in) != c.ok {
+			t.Fatalf("validEmoji(%q) expected %v", c.in, c.ok)
+		}
+	}
+}
+
+func TestAddReaction_LimitUnderMessageLock(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM messages(.+)FOR NO KEY UPDATE`).
+		WithArgs(int64(10), int64(1), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM message_reactions`).
+		WithArgs(int64(10), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(maxReactionsPerUser))
+	mock.ExpectRollback()
+
+	if _, err := s.AddReaction(context.Background(), 1, 10, 7, "👍"); !errors.Is(err, ErrTooManyReactions) {
+		t.Fatalf("want ErrTooManyReactions, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAddReaction_Inserts(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM messages(.+)FOR NO KEY UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM message_reactions`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO message_reactions`).
+		WithArgs(int64(10), int64(7), "🎉").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	added, err := s.AddReaction(context.Background(), 1, 10, 7, "🎉")
+	if err != nil || !added {
+		t.Fatalf("added=%v err=%v", added, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// MaxUnreadCount bounds the per-room unread scan; clients show "999+".
+const MaxUnreadCount = 1000
+
+// MarkRoomRead moves the user's read marker to msgID (nil: newest message).
+// Markers only move forward; the current marker is returned either way and
+// moved reports whether it advanced. Mentions up to the marker are marked read too.
+func (s *Store) MarkRoomRead(ctx context.Context, roomID, userID int64, msgID *int64) (rm models.ReadMarker, moved bool, err error) {
+	rm = models.ReadMarker{RoomID: roomID, UserID: userID}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return rm, false, err
+	}
+	defer tx.Rollback()
+
+	var (
+		target  sql.NullInt64
+		created sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, created_at FROM messages
+		WHERE room_id = $1 AND ($3::bigint IS NULL OR id = $3)
+		  AND `+roomVisible("$1", "$2")+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, roomID, userID, msgID).Scan(&target, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		if msgID != nil {
+			return rm, false, ErrNotFound
+		}
+		return rm, false, nil // empty room, nothing to read
+	}
+	if err != nil {
+		return rm, false, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO room_reads (room_id, user_id, last_read_message_id, last_read_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET last_read_message_id = EXCLUDED.last_read_message_id,
+		    last_read_at         = EXCLUDED.last_read_at,
+		    updated_at           = NOW()
+		WHERE (room_reads.last_read_at, room_reads.last_read_message_id)
+		    < (EXCLUDED.last_read_at, EXCLUDED.last_read_message_id)
+		RETURNING last_read_message_id, updated_at
+	`, roomID, userID, target.Int64, created.Time).Scan(&rm.MessageID, &rm.ReadAt)
+	moved = err == nil
+	if errors.Is(err, sql.ErrNoRows) {
+		// marker already ahead
+		err = tx.QueryRowContext(ctx, `
+			SELECT last_read_message_id, updated_at FROM room_reads WHERE room_id = $1 AND user_id = $2
+		`, roomID, userID).Scan(&rm.MessageID, &rm.ReadAt)
+	}
+	if err != nil {
+		return rm, false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE mentions mn SET read_at = NOW()
+		FROM messages m
+		WHERE mn.user_id = $2 AND mn.room_id = $1 AND mn.read_at IS NULL
+		  AND m.id = mn.message_id
+		  AND (m.created_at, m.id) <= (SELECT last_read_at, last_read_message_id FROM room_reads WHERE room_id = $1 AND user_id = $2)
+	`, roomID, userID); err != nil {
+		return rm, false, err
+	}
+	return rm, moved, tx.Commit()
+}
+
+// ListReceipts returns who has read up to msgID, excluding its author and
+// users who opted out of read receipts. Capped at 100, earliest readers first.
+func (s *Store) ListReceipts(ctx context.Context, roomID, msgID, viewerID int64) ([]models.Receipt, error) {
+	var exists bool
+	if err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM messages WHERE id = $1 AND room_id = $2)
+		   AND `+roomVisible("$2", "$3")+`
+	`, msgID, roomID, viewerID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT rr.user_id, u.username, rr.updated_at
+		FROM messages m
+		JOIN room_reads rr ON rr.room_id = m.room_id
+		 AND (rr.last_read_at, rr.last_read_message_id) >= (m.created_at, m.id)
+		JOIN users u ON u.id = rr.user_id AND u.share_read_receipts
+		WHERE m.id = $1 AND m.room_id = $2 AND rr.user_id <> m.user_id
+		ORDER BY rr.updated_at ASC
+		LIMIT 100
+	`, msgID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.Receipt{}
+	for rows.Next() {
+		var r models.Receipt
+		if err := rows.Scan(&r.UserID, &r.Username, &r.ReadAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// attachUnreadCounts fills UnreadCount and MentionCount for the viewer.
+// Unread counts walk messages_room_created_at from the read marker and stop
+// at MaxUnreadCount; thread replies, tombstones and own messages don't count.
+func (s *Store) attachUnreadCounts(ctx context.Context, viewerID int64, rooms []models.Room) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	idx := make(map[int64]int, len(rooms))
+	ids := make([]int64, 0, len(rooms))
+	for i, r := range rooms {
+		idx[r.ID] = i
+		ids = append(ids, r.ID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT r.id,
+		       (SELECT COUNT(*) FROM (
+		            SELECT 1 FROM messages m
+		            WHERE m.room_id = r.id
+		              AND (m.created_at, m.id) > (COALESCE(rr.last_read_at, '-infinity'), COALESCE(rr.last_read_message_id, 0))
+		              AND m.parent_id IS NULL AND m.deleted_at IS NULL AND m.user_id <> $2
+		              AND (m.expires_at IS NULL OR m.expires_at > NOW())
+		            LIMIT $3
+		        ) u),
+		       (SELECT COUNT(*) FROM mentions mn
+		        WHERE mn.room_id = r.id AND mn.user_id = $2 AND mn.read_at IS NULL)
+		FROM unnest($1::bigint[]) AS r(id)
+		LEFT JOIN room_reads rr ON rr.room_id = r.id AND rr.user_id = $2
+	`, pq.Array(ids), viewerID, MaxUnreadCount)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var unread, mentions int
+		if err := rows.Scan(&id, &unread, &mentions); err != nil {
+			return err
+		}
+		if i, ok := idx[id]; ok {
+			rooms[i].UnreadCount = unread
+			rooms[i].MentionCount = mentions
+		}
+	}
+	return rows.Err()
+}
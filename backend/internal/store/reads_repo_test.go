@@ -0,0 +1,125 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMarkRoomRead_MovesForward(t *testing.T) {
+	s, mock := newMockStore(t)
+	ts := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, created_at FROM messages`).
+		WithArgs(int64(1), int64(7), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, ts))
+	mock.ExpectQuery(`INSERT INTO room_reads(.+)WHERE \(room_reads.last_read_at, room_reads.last_read_message_id\)\s+< \(EXCLUDED.last_read_at, EXCLUDED.last_read_message_id\)`).
+		WithArgs(int64(1), int64(7), int64(42), ts).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id", "updated_at"}).AddRow(42, ts))
+	mock.ExpectExec(`UPDATE mentions mn SET read_at = NOW\(\)`).
+		WithArgs(int64(1), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	rm, moved, err := s.MarkRoomRead(context.Background(), 1, 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !moved || rm.MessageID != 42 || rm.RoomID != 1 || rm.UserID != 7 {
+		t.Fatalf("moved=%v marker=%+v", moved, rm)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestMarkRoomRead_NeverMovesBack(t *testing.T) {
+	s, mock := newMockStore(t)
+	ts := time.Now()
+	older := int64(40)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, created_at FROM messages`).
+		WithArgs(int64(1), int64(7), &older).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(40, ts.Add(-time.Minute)))
+	mock.ExpectQuery(`INSERT INTO room_reads`).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id", "updated_at"}))
+	mock.ExpectQuery(`SELECT last_read_message_id, updated_at FROM room_reads`).
+		WithArgs(int64(1), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id", "updated_at"}).AddRow(42, ts))
+	mock.ExpectExec(`UPDATE mentions`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	rm, moved, err := s.MarkRoomRead(context.Background(), 1, 7, &older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved || rm.MessageID != 42 {
+		t.Fatalf("marker went back: moved=%v marker=%+v", moved, rm)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestMarkRoomRead_UnknownMessage(t *testing.T) {
+	s, mock := newMockStore(t)
+	id := int64(99)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, created_at FROM messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+	mock.ExpectRollback()
+
+	if _, _, err := s.MarkRoomRead(context.Background(), 1, 7, &id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestMarkRoomRead_EmptyRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, created_at FROM messages`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+	mock.ExpectRollback()
+
+	if _, moved, err := s.MarkRoomRead(context.Background(), 1, 7, nil); err != nil || moved {
+		t.Fatalf("moved=%v err=%v", moved, err)
+	}
+}
+
+func TestAttachUnreadCounts_CappedScan(t *testing.T) {
+	s, mock := newMockStore(t)
+	rooms := []models.Room{{ID: 1}, {ID: 2}}
+	mock.ExpectQuery(`SELECT 1 FROM messages m(.+)m.parent_id IS NULL AND m.deleted_at IS NULL AND m.user_id <> \$2\s+AND \(m.expires_at IS NULL OR m.expires_at > NOW\(\)\)\s+LIMIT \$3`).
+		WithArgs(sqlmock.AnyArg(), int64(7), MaxUnreadCount).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "unread", "mentions"}).
+			AddRow(1, MaxUnreadCount, 3).
+			AddRow(2, 0, 0))
+
+	if err := s.attachUnreadCounts(context.Background(), 7, rooms); err != nil {
+		t.Fatal(err)
+	}
+	if rooms[0].UnreadCount != MaxUnreadCount || rooms[0].MentionCount != 3 {
+		t.Fatalf("room 1 = %+v", rooms[0])
+	}
+	if rooms[1].UnreadCount != 0 || rooms[1].MentionCount != 0 {
+		t.Fatalf("room 2 = %+v", rooms[1])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestAttachUnreadCounts_NoRooms(t *testing.T) {
+	s, mock := newMockStore(t)
+	if err := s.attachUnreadCounts(context.Background(), 7, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("no query expected: %v", err)
+	}
+}
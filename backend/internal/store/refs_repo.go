@@ -0,0 +1,73 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+const excerptRunes = 140
+
+// excerpt flattens whitespace and cuts s to excerptRunes runes.
+func excerpt(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= excerptRunes {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:excerptRunes]) + "…"
+}
+
+// attachReplyRefs fills ReplyTo snapshots for messages quoting another one.
+// Quoted tombstones, expired ephemeral messages included, keep their author
+// but lose the excerpt.
+func (s *Store) attachReplyRefs(ctx context.Context, items []models.Message) error {
+	var ids []int64
+	for _, m := range items {
+		if m.ReplyToID != nil {
+			ids = append(ids, *m.ReplyToID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT m.id, m.user_id, u.username, m.content,
+		       m.deleted_at IS NOT NULL OR m.expires_at <= NOW()
+		FROM messages m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	refs := make(map[int64]*models.MessageRef, len(ids))
+	for rows.Next() {
+		var (
+			r       models.MessageRef
+			content string
+		)
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Username, &content, &r.Deleted); err != nil {
+			return err
+		}
+		if !r.Deleted {
+			r.Excerpt = excerpt(content)
+		}
+		refs[r.ID] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range items {
+		if items[i].ReplyToID != nil {
+			items[i].ReplyTo = refs[*items[i].ReplyToID]
+		}
+	}
+	return nil
+}
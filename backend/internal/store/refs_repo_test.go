@@ -0,0 +1,21 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExcerpt(t *testing.T) {
+	if got := excerpt("  hello\n\n  world\t"); got != "hello world" {
+		t.Fatalf("excerpt collapsed whitespace = %q", got)
+	}
+	long := strings.Repeat("字", excerptRunes+10)
+	got := excerpt(long)
+	if n := utf8.RuneCountInString(got); n != excerptRunes+1 {
+		t.Fatalf("want %d runes (with ellipsis), got %d", excerptRunes+1, n)
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Fatalf("expected ellipsis, got %q", got)
+	}
+}
@@ -3,22 +3,238 @@ package store
 import (
 	"backend/internal/models"
 	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
 )
 
-func (s *Store) ListRooms(ctx context.Context) ([]models.Room, error) {
-	rows, err := s.DB.QueryContext(ctx, `SELECT id, name, is_public FROM rooms ORDER BY id ASC`)
+var ErrNameTaken = errors.New("name taken")
+
+// roomVisible is the tenant and membership predicate every room-scoped query
+// goes through: workspace rooms require workspace membership, private rooms
+// (and DMs) require room membership on top of that.
+func roomVisible(roomIDExpr, userIDExpr string) string {
+	return `EXISTS (
+		SELECT 1 FROM rooms vr
+		WHERE vr.id = ` + roomIDExpr + `
+		  AND (vr.workspace_id IS NULL OR EXISTS (
+			SELECT 1 FROM workspace_members vwm
+			WHERE vwm.workspace_id = vr.workspace_id AND vwm.user_id = ` + userIDExpr + `
+		  ))
+		  AND (vr.is_public OR EXISTS (
+			SELECT 1 FROM room_members vm
+			WHERE vm.room_id = vr.id AND vm.user_id = ` + userIDExpr + `
+		  ))
+	)`
+}
+
+// ListRooms returns the global (workspace-less) channels visible to the
+// viewer, with their unread and mention counts.
+func (s *Store) ListRooms(ctx context.Context, viewerID int64) ([]models.Room, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT r.id, r.workspace_id, r.name, r.is_public FROM rooms r
+		WHERE r.kind = 'channel' AND r.workspace_id IS NULL
+		  AND `+roomVisible("r.id", "$1")+`
+		ORDER BY r.id ASC
+	`, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rooms, err := scanRooms(rows)
+	if err != nil {
+		return nil, err
+	}
+	return rooms, s.attachUnreadCounts(ctx, viewerID, rooms)
+}
+
+// ListWorkspaceRooms returns the channels of a workspace the user can see.
+// Non-members get an empty list, never another tenant's rooms.
+func (s *Store) ListWorkspaceRooms(ctx context.Context, workspaceID, userID int64) ([]models.Room, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT r.id, r.workspace_id, r.name, r.is_public FROM rooms r
+		WHERE r.kind = 'channel' AND r.workspace_id = $1
+		  AND `+roomVisible("r.id", "$2")+`
+		ORDER BY r.name ASC
+	`, workspaceID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	rooms, err := scanRooms(rows)
+	if err != nil {
+		return nil, err
+	}
+	return rooms, s.attachUnreadCounts(ctx, userID, rooms)
+}
 
+func scanRooms(rows *sql.Rows) ([]models.Room, error) {
 	var out []models.Room
 	for rows.Next() {
-		var r models.Room
-		if err := rows.Scan(&r.ID, &r.Name, &r.IsPublic); err != nil {
+		var (
+			r    models.Room
+			wsID sql.NullInt64
+		)
+		if err := rows.Scan(&r.ID, &wsID, &r.Name, &r.IsPublic); err != nil {
 			return nil, err
 		}
+		if wsID.Valid {
+			r.WorkspaceID = &wsID.Int64
+		}
 		out = append(out, r)
 	}
 	return out, rows.Err()
 }
+
+// CreateWorkspaceRoom adds a channel to a workspace; the creator becomes its owner.
+// Names are unique per workspace (rooms_workspace_name_unique).
+func (s *Store) CreateWorkspaceRoom(ctx context.Context, workspaceID, creatorID int64, name string, isPublic bool) (models.Room, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Room{}, err
+	}
+	defer tx.Rollback()
+
+	r := models.Room{WorkspaceID: &workspaceID, Name: name, IsPublic: isPublic}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO rooms (workspace_id, name, is_public)
+		SELECT $1::bigint, $2, $3::boolean
+		WHERE EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = $1 AND user_id = $4)
+		RETURNING id
+	`, workspaceID, name, isPublic, creatorID).Scan(&r.ID)
+	if err != nil {
+		var pqe *pq.Error
+		if errors.As(err, &pqe) && pqe.Code == "23505" { // duplicate key value violates
+			return models.Room{}, ErrNameTaken
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Room{}, ErrNotFound
+		}
+		return models.Room{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $3)
+	`, r.ID, creatorID, models.RoleOwner); err != nil {
+		return models.Room{}, err
+	}
+	return r, tx.Commit()
+}
+
+// CanAccessRoom reports whether the user may read and post in the room.
+func (s *Store) CanAccessRoom(ctx context.Context, roomID, userID int64) (bool, error) {
+	var ok bool
+	err := s.DB.QueryRowContext(ctx, `SELECT `+roomVisible("$1", "$2"), roomID, userID).Scan(&ok)
+	return ok, err
+}
+
+// RoomRole returns the user's role in the room, or ErrNotFound for non-members.
+func (s *Store) RoomRole(ctx context.Context, roomID, userID int64) (string, error) {
+	var role string
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2
+	`, roomID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+// SetRoomRole changes targetID's role in the room on behalf of actorID.
+// Owners and admins manage roles, only owners grant or take away admin, and
+// ownership itself never moves this way. The target must be able to see the
+// room; in public rooms that gives them a member row.
+func (s *Store) SetRoomRole(ctx context.Context, roomID, actorID, targetID int64, role string) error {
+	if role == models.RoleOwner {
+		return ErrForbidden
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		actorRole, targetRole sql.NullString
+		visible               bool
+	)
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2),
+			(SELECT role FROM room_members WHERE room_id = $1 AND user_id = $3),
+			`+roomVisible("$1", "$3")+`
+	`, roomID, actorID, targetID).Scan(&actorRole, &targetRole, &visible); err != nil {
+		return err
+	}
+	switch {
+	case actorRole.String != models.RoleOwner && actorRole.String != models.RoleAdmin:
+		return ErrForbidden
+	case !visible:
+		return ErrNotFound
+	case targetRole.String == models.RoleOwner:
+		return ErrForbidden
+	case (role == models.RoleAdmin || targetRole.String == models.RoleAdmin) && actorRole.String != models.RoleOwner:
+		return ErrForbidden
+	case !targetRole.Valid && role == models.RoleMember:
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`, roomID, targetID, role); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetChannelOwner makes the user with the given email an owner of the global
+// channel with the given name. It is for operators (CHANNEL_OWNERS at
+// startup); no request path reaches it. ErrNotFound when either is missing.
+func (s *Store) SetChannelOwner(ctx context.Context, channel, email string) error {
+	res, err := s.DB.ExecContext(ctx, `
+		INSERT INTO room_members (room_id, user_id, role)
+		SELECT r.id, u.id, 'owner'
+		FROM rooms r, users u
+		WHERE r.name = $1 AND r.kind = 'channel' AND r.workspace_id IS NULL
+		  AND u.email = $2
+		ON CONFLICT (room_id, user_id) DO UPDATE SET role = 'owner'
+	`, channel, email)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemberRoomIDs lists the rooms the user holds a membership in.
+func (s *Store) MemberRoomIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return s.queryIDs(ctx, `SELECT room_id FROM room_members WHERE user_id = $1`, userID)
+}
+
+// VisibleUserIDs keeps the ids the viewer shares a workspace or a visible room
+// with. Public rooms have no member rows, so posting in one counts too.
+func (s *Store) VisibleUserIDs(ctx context.Context, viewerID int64, ids []int64) ([]int64, error) {
+	return s.queryIDs(ctx, `
+		SELECT u.id FROM users u
+		WHERE u.id = ANY($2)
+		  AND (
+			u.id = $1
+			OR EXISTS (
+				SELECT 1 FROM workspace_members a
+				JOIN workspace_members b ON b.workspace_id = a.workspace_id
+				WHERE a.user_id = $1 AND b.user_id = u.id
+			)
+			OR EXISTS (
+				SELECT 1 FROM room_members rm
+				WHERE rm.user_id = u.id AND `+roomVisible("rm.room_id", "$1")+`
+			)
+			OR EXISTS (
+				SELECT 1 FROM messages m
+				WHERE m.user_id = u.id AND `+roomVisible("m.room_id", "$1")+`
+			)
+		  )
+	`, viewerID, pq.Array(ids))
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRoomVisible_BindsBothChecksToTheUser(t *testing.T) {
+	q := roomVisible("r.id", "$9")
+	for _, want := range []string{
+		"vr.id = r.id",
+		"vr.workspace_id IS NULL OR EXISTS",
+		"vwm.workspace_id = vr.workspace_id AND vwm.user_id = $9",
+		"vr.is_public OR EXISTS",
+		"vm.room_id = vr.id AND vm.user_id = $9",
+	} {
+		if !strings.Contains(q, want) {
+			t.Fatalf("roomVisible is missing %q:\n%s", want, q)
+		}
+	}
+}
+
+func TestCanAccessRoom(t *testing.T) {
+	for _, visible := range []bool{true, false} {
+		s, mock := newMockStore(t)
+		mock.ExpectQuery(`SELECT EXISTS \(\s*SELECT 1 FROM rooms vr\s*WHERE vr.id = \$1(.+)vwm.user_id = \$2(.+)vm.user_id = \$2`).
+			WithArgs(int64(1), int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(visible))
+
+		ok, err := s.CanAccessRoom(context.Background(), 1, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != visible {
+			t.Fatalf("CanAccessRoom = %v, want %v", ok, visible)
+		}
+	}
+}
+
+func TestListWorkspaceRooms_NonMemberSeesNothing(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM rooms r\s+WHERE r.kind = 'channel' AND r.workspace_id = \$1\s+AND EXISTS \(\s*SELECT 1 FROM rooms vr\s*WHERE vr.id = r.id(.+)vwm.user_id = \$2`).
+		WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "workspace_id", "name", "is_public"}))
+
+	rooms, err := s.ListWorkspaceRooms(context.Background(), 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 0 {
+		t.Fatalf("want no rooms, got %+v", rooms)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCreateWorkspaceRoom_RequiresWorkspaceMembership(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rooms(.+)WHERE EXISTS \(SELECT 1 FROM workspace_members WHERE workspace_id = \$1 AND user_id = \$4\)`).
+		WithArgs(int64(3), "ops", false, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err := s.CreateWorkspaceRoom(context.Background(), 3, 7, "ops", false)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCreateWorkspaceRoom_CreatorOwnsRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO rooms`).
+		WithArgs(int64(3), "ops", true, int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectExec(`INSERT INTO room_members \(room_id, user_id, role\)`).
+		WithArgs(int64(40), int64(7), "owner").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r, err := s.CreateWorkspaceRoom(context.Background(), 3, 7, "ops", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != 40 || r.WorkspaceID == nil || *r.WorkspaceID != 3 {
+		t.Fatalf("unexpected room %+v", r)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func expectRoleCheck(mock sqlmock.Sqlmock, actorRole, targetRole any, visible bool) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT role FROM room_members(.+)SELECT 1 FROM rooms vr`).
+		WithArgs(int64(1), int64(7), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"actor", "target", "visible"}).AddRow(actorRole, targetRole, visible))
+}
+
+func TestSetRoomRole_AdminAppointsModerator(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectRoleCheck(mock, "admin", nil, true)
+	mock.ExpectExec(`INSERT INTO room_members(.+)ON CONFLICT \(room_id, user_id\) DO UPDATE SET role = EXCLUDED.role`).
+		WithArgs(int64(1), int64(8), "moderator").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.SetRoomRole(context.Background(), 1, 7, 8, "moderator"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestSetRoomRole_Rejections(t *testing.T) {
+	cases := []struct {
+		name       string
+		actorRole  any
+		targetRole any
+		visible    bool
+		role       string
+		want       error
+	}{
+		{"plain member", "member", nil, true, "moderator", ErrForbidden},
+		{"moderator", "moderator", nil, true, "moderator", ErrForbidden},
+		{"outsider target", "owner", nil, false, "moderator", ErrNotFound},
+		{"demote owner", "admin", "owner", true, "member", ErrForbidden},
+		{"admin grants admin", "admin", "moderator", true, "admin", ErrForbidden},
+		{"admin demotes admin", "admin", "admin", true, "member", ErrForbidden},
+	}
+	for _, tc := range cases {
+		s, mock := newMockStore(t)
+		expectRoleCheck(mock, tc.actorRole, tc.targetRole, tc.visible)
+		mock.ExpectRollback()
+
+		if err := s.SetRoomRole(context.Background(), 1, 7, 8, tc.role); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: want %v, got %v", tc.name, tc.want, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: unmet sql expectations: %v", tc.name, err)
+		}
+	}
+}
+
+func TestSetRoomRole_OwnerPromotesAdmin(t *testing.T) {
+	s, mock := newMockStore(t)
+	expectRoleCheck(mock, "owner", "moderator", true)
+	mock.ExpectExec(`INSERT INTO room_members`).
+		WithArgs(int64(1), int64(8), "admin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.SetRoomRole(context.Background(), 1, 7, 8, "admin"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetRoomRole_OwnershipDoesNotMove(t *testing.T) {
+	s, _ := newMockStore(t)
+	if err := s.SetRoomRole(context.Background(), 1, 7, 8, "owner"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("want ErrForbidden, got %v", err)
+	}
+}
+
+func TestSetChannelOwner(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectExec(`INSERT INTO room_members(.+)r.name = \$1 AND r.kind = 'channel' AND r.workspace_id IS NULL\s+AND u.email = \$2`).
+		WithArgs("general", "alice@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO room_members`).
+		WithArgs("general", "nobody@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.SetChannelOwner(context.Background(), "general", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChannelOwner(context.Background(), "general", "nobody@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestVisibleUserIDs_SharedWorkspaceOrRoom(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM users u\s+WHERE u.id = ANY\(\$2\)(.+)a.user_id = \$1 AND b.user_id = u.id(.+)FROM room_members rm(.+)vr.id = rm.room_id(.+)FROM messages m(.+)vr.id = m.room_id`).
+		WithArgs(int64(7), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7).AddRow(8))
+
+	ids, err := s.VisibleUserIDs(context.Background(), 7, []int64{7, 8, 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[1] != 8 {
+		t.Fatalf("ids = %v", ids)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
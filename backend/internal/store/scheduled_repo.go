@@ -0,0 +1,232 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrTooManyScheduled = errors.New("too many scheduled messages")
+
+// MaxScheduledPerUser caps a user's pending scheduled messages.
+const MaxScheduledPerUser = 100
+
+const scheduledColumns = `id, room_id, user_id, content, parent_id, reply_to_id, send_at, status, error, message_id, created_at, updated_at`
+
+func scanScheduled(row rowScanner) (models.ScheduledMessage, error) {
+	var (
+		sm                             models.ScheduledMessage
+		parentID, replyToID, messageID sql.NullInt64
+		errText                        sql.NullString
+	)
+	err := row.Scan(&sm.ID, &sm.RoomID, &sm.UserID, &sm.Content, &parentID, &replyToID,
+		&sm.SendAt, &sm.Status, &errText, &messageID, &sm.CreatedAt, &sm.UpdatedAt)
+	if err != nil {
+		return sm, err
+	}
+	if parentID.Valid {
+		sm.ParentID = &parentID.Int64
+	}
+	if replyToID.Valid {
+		sm.ReplyToID = &replyToID.Int64
+	}
+	if messageID.Valid {
+		sm.MessageID = &messageID.Int64
+	}
+	sm.Error = errText.String
+	return sm, nil
+}
+
+// CreateScheduled stores a message to send at in.SendAt. Parent and reply
+// targets are checked when it is sent, since they may change meanwhile. The
+// author's user row is locked while counting, so concurrent requests cannot
+// go past MaxScheduledPerUser together.
+func (s *Store) CreateScheduled(ctx context.Context, in NewMessage, sendAt time.Time) (models.ScheduledMessage, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ScheduledMessage{}, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM scheduled_messages WHERE user_id = u.id AND status = 'pending')
+		FROM users u WHERE u.id = $1
+		FOR UPDATE OF u
+	`, in.UserID).Scan(&n); err != nil {
+		return models.ScheduledMessage{}, err
+	}
+	if n >= MaxScheduledPerUser {
+		return models.ScheduledMessage{}, ErrTooManyScheduled
+	}
+	sm, err := scanScheduled(tx.QueryRowContext(ctx, `
+		INSERT INTO scheduled_messages (room_id, user_id, content, parent_id, reply_to_id, send_at)
+		SELECT $1::bigint, $2::bigint, $3, $4::bigint, $5::bigint, $6
+		WHERE `+roomVisible("$1", "$2")+`
+		RETURNING `+scheduledColumns,
+		in.RoomID, in.UserID, in.Content, in.ParentID, in.ReplyToID, sendAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sm, ErrNotFound
+	}
+	if err != nil {
+		return sm, err
+	}
+	return sm, tx.Commit()
+}
+
+// GetScheduled returns one of the user's pending scheduled messages; sent,
+// failed or foreign ones are ErrNotFound.
+func (s *Store) GetScheduled(ctx context.Context, userID, id int64) (models.ScheduledMessage, error) {
+	sm, err := scanScheduled(s.DB.QueryRowContext(ctx, `
+		SELECT `+scheduledColumns+`
+		FROM scheduled_messages
+		WHERE id = $1 AND user_id = $2 AND status = 'pending'
+	`, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sm, ErrNotFound
+	}
+	return sm, err
+}
+
+// ListScheduled returns the user's pending and failed scheduled messages,
+// soonest first, optionally in one room.
+func (s *Store) ListScheduled(ctx context.Context, userID int64, roomID *int64) ([]models.ScheduledMessage, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+scheduledColumns+`
+		FROM scheduled_messages
+		WHERE user_id = $1 AND status <> 'sent'
+		  AND ($2::bigint IS NULL OR room_id = $2)
+		ORDER BY send_at, id
+	`, userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []models.ScheduledMessage{}
+	for rows.Next() {
+		sm, err := scanScheduled(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sm)
+	}
+	return out, rows.Err()
+}
+
+// UpdateScheduled changes the content and/or send time of a pending
+// scheduled message. Sent, failed or foreign ones are ErrNotFound.
+func (s *Store) UpdateScheduled(ctx context.Context, userID, id int64, content *string, sendAt *time.Time) (models.ScheduledMessage, error) {
+	sm, err := scanScheduled(s.DB.QueryRowContext(ctx, `
+		UPDATE scheduled_messages
+		SET content = COALESCE($3, content), send_at = COALESCE($4, send_at), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = 'pending'
+		RETURNING `+scheduledColumns, id, userID, content, sendAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sm, ErrNotFound
+	}
+	return sm, err
+}
+
+// CancelScheduled deletes a pending or failed scheduled message.
+func (s *Store) CancelScheduled(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM scheduled_messages WHERE id = $1 AND user_id = $2 AND status <> 'sent'
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DispatchScheduled sends the oldest scheduled message due at now, or
+// returns ErrNotFound when none is. The row stays locked (SKIP LOCKED for
+// other instances) until the message is inserted and the row marked sent in
+// the same transaction, so no instance can post it twice. If the author can
+// no longer post it (left the room, thread root gone) it is marked failed
+// and m is zero.
+func (s *Store) DispatchScheduled(ctx context.Context, now time.Time) (sm models.ScheduledMessage, m models.Message, err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return sm, m, err
+	}
+	defer tx.Rollback()
+
+	sm, err = scanScheduled(tx.QueryRowContext(ctx, `
+		SELECT `+scheduledColumns+`
+		FROM scheduled_messages
+		WHERE status = 'pending' AND send_at <= $1
+		ORDER BY send_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, now))
+	if errors.Is(err, sql.ErrNoRows) {
+		return sm, m, ErrNotFound
+	}
+	if err != nil {
+		return sm, m, err
+	}
+
+	m, err = insertMessage(ctx, tx, NewMessage{
+		RoomID: sm.RoomID, UserID: sm.UserID, Content: sm.Content,
+		ParentID: sm.ParentID, ReplyToID: sm.ReplyToID,
+	})
+	var reason string
+	switch {
+	case errors.Is(err, ErrNotFound):
+		reason = "room not accessible"
+	case errors.Is(err, ErrInvalidParent):
+		reason = "thread root no longer exists"
+	case errors.Is(err, ErrInvalidReplyTo):
+		reason = "quoted message no longer exists"
+	case err != nil:
+		return sm, models.Message{}, err
+	}
+
+	if reason != "" {
+		m = models.Message{}
+		sm, err = scanScheduled(tx.QueryRowContext(ctx, `
+			UPDATE scheduled_messages SET status = 'failed', error = $2, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+scheduledColumns, sm.ID, reason))
+	} else {
+		sm, err = scanScheduled(tx.QueryRowContext(ctx, `
+			UPDATE scheduled_messages SET status = 'sent', message_id = $2, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+scheduledColumns, sm.ID, m.ID))
+	}
+	if err != nil {
+		return sm, models.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return sm, models.Message{}, err
+	}
+	if m.ID == 0 {
+		return sm, m, nil
+	}
+	items := []models.Message{m}
+	if err := s.attachReplyRefs(ctx, items); err != nil {
+		return sm, m, err
+	}
+	return sm, items[0], nil
+}
+
+// PruneSentScheduled deletes up to batch scheduled messages sent before
+// cutoff. Nobody lists them once sent; the message itself stays.
+func (s *Store) PruneSentScheduled(ctx context.Context, cutoff time.Time, batch int) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM scheduled_messages
+		WHERE id IN (
+			SELECT id FROM scheduled_messages
+			WHERE status = 'sent' AND updated_at < $1
+			LIMIT $2
+		)
+	`, cutoff, batch)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var scheduledCols = []string{"id", "room_id", "user_id", "content", "parent_id", "reply_to_id", "send_at", "status", "error", "message_id", "created_at", "updated_at"}
+
+func scheduledRow(status string, errText, messageID any) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(scheduledCols).
+		AddRow(5, 1, 7, "good morning", nil, nil, now, status, errText, messageID, now, now)
+}
+
+func TestDispatchScheduled_NothingDue(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM scheduled_messages(.+)FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows(scheduledCols))
+	mock.ExpectRollback()
+
+	if _, _, err := s.DispatchScheduled(context.Background(), time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDispatchScheduled_SendsInOneTransaction(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM scheduled_messages(.+)FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(scheduledRow("pending", nil, nil))
+	mock.ExpectQuery(`INSERT INTO messages`).
+		WithArgs(int64(1), int64(7), "good morning", nil, nil, 0).
+		WillReturnRows(messageRow(40, 1, 7, "good morning", time.Now()))
+	mock.ExpectQuery(`UPDATE scheduled_messages SET status = 'sent'`).
+		WithArgs(int64(5), int64(40)).
+		WillReturnRows(scheduledRow("sent", nil, 40))
+	mock.ExpectCommit()
+
+	sm, m, err := s.DispatchScheduled(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 40 || sm.Status != "sent" || sm.MessageID == nil || *sm.MessageID != 40 {
+		t.Fatalf("got sm=%+v m=%+v", sm, m)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestDispatchScheduled_FailsWhenRoomGone(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM scheduled_messages(.+)FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(scheduledRow("pending", nil, nil))
+	mock.ExpectQuery(`INSERT INTO messages`).
+		WillReturnRows(sqlmock.NewRows(messageCols))
+	mock.ExpectQuery(`UPDATE scheduled_messages SET status = 'failed'`).
+		WithArgs(int64(5), "room not accessible").
+		WillReturnRows(scheduledRow("failed", "room not accessible", nil))
+	mock.ExpectCommit()
+
+	sm, m, err := s.DispatchScheduled(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 0 || sm.Status != "failed" || sm.Error != "room not accessible" {
+		t.Fatalf("got sm=%+v m=%+v", sm, m)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestPruneSentScheduled_OnlySent(t *testing.T) {
+	s, mock := newMockStore(t)
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	// pending and failed rows are still the user's to edit or cancel
+	mock.ExpectExec(`DELETE FROM scheduled_messages(.+)status = 'sent' AND updated_at < \$1`).
+		WithArgs(cutoff, 500).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := s.PruneSentScheduled(context.Background(), cutoff, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("pruned %d rows, want 3", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCreateScheduled_CapCountedUnderUserLock(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	// the lock makes a concurrent request wait for this count and insert
+	mock.ExpectQuery(`SELECT \(SELECT COUNT\(\*\) FROM scheduled_messages WHERE user_id = u.id AND status = 'pending'\)\s+FROM users u WHERE u.id = \$1\s+FOR UPDATE OF u`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(MaxScheduledPerUser))
+	mock.ExpectRollback()
+
+	_, err := s.CreateScheduled(context.Background(), NewMessage{RoomID: 1, UserID: 7, Content: "later"}, time.Now().Add(time.Hour))
+	if !errors.Is(err, ErrTooManyScheduled) {
+		t.Fatalf("want ErrTooManyScheduled, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestCreateScheduled_InsertsInTheSameTransaction(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM users u WHERE u.id = \$1\s+FOR UPDATE OF u`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`INSERT INTO scheduled_messages`).
+		WillReturnRows(scheduledRow("pending", nil, nil))
+	mock.ExpectCommit()
+
+	sm, err := s.CreateScheduled(context.Background(), NewMessage{RoomID: 1, UserID: 7, Content: "good morning"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.ID != 5 || sm.Status != "pending" {
+		t.Fatalf("got %+v", sm)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
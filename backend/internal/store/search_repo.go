@@ -0,0 +1,85 @@
+package store
+
+import (
+	"backend/internal/models"
+	"backend/internal/search"
+	"context"
+	"fmt"
+	"time"
+)
+
+// headlineOptions shape ts_headline snippets; the delimiters are swapped for
+// <mark> tags by search.Highlight after escaping.
+var headlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=24, MinWords=8, MaxFragments=2, FragmentDelimiter=\" … \"",
+	search.StartSel, search.StopSel)
+
+// SearchMessages returns live messages matching q in rooms the viewer can
+// see, newest first, paging backwards from (beforeTS, beforeID). Ephemeral
+// messages are never searchable, even before they expire.
+func (s *Store) SearchMessages(ctx context.Context, viewerID int64, q search.Query, limit int, beforeTS *time.Time, beforeID *int64) ([]models.SearchHit, error) {
+	args := []any{viewerID, q.Text, headlineOptions}
+	query := `
+		SELECT ` + mMessageColumns + `,
+		       r.name, ts_headline('simple', m.content, tq, $3)
+		FROM messages m
+		JOIN rooms r ON r.id = m.room_id
+		CROSS JOIN websearch_to_tsquery('simple', $2) tq
+		WHERE m.content_tsv @@ tq
+		  AND m.deleted_at IS NULL AND m.expires_at IS NULL
+		  AND ` + roomVisible("m.room_id", "$1")
+	if q.From != "" {
+		args = append(args, q.From)
+		query += fmt.Sprintf(` AND m.user_id IN (SELECT id FROM users WHERE LOWER(username) = $%d)`, len(args))
+	}
+	if q.In != "" {
+		args = append(args, q.In)
+		query += fmt.Sprintf(` AND LOWER(r.name) = $%d`, len(args))
+	}
+	if q.Before != nil {
+		args = append(args, *q.Before)
+		query += fmt.Sprintf(` AND m.created_at < $%d`, len(args))
+	}
+	if q.After != nil {
+		args = append(args, *q.After)
+		query += fmt.Sprintf(` AND m.created_at >= $%d`, len(args))
+	}
+	if beforeTS != nil && beforeID != nil {
+		args = append(args, *beforeTS, *beforeID)
+		query += fmt.Sprintf(` AND (m.created_at, m.id) < ($%d, $%d)`, len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY m.created_at DESC, m.id DESC LIMIT $%d`, len(args))
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []models.SearchHit
+	for rows.Next() {
+		var (
+			hit     models.SearchHit
+			snippet string
+		)
+		m, err := scanMessage(trailingRow{rows, []any{&hit.RoomName, &snippet}})
+		if err != nil {
+			return nil, err
+		}
+		hit.Message = m
+		hit.Snippet = search.Highlight(snippet)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// trailingRow lets scanMessage read the message columns of a wider row and
+// routes the columns after them to extra.
+type trailingRow struct {
+	rowScanner
+	extra []any
+}
+
+func (r trailingRow) Scan(dest ...any) error {
+	return r.rowScanner.Scan(append(dest, r.extra...)...)
+}
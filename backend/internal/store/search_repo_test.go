@@ -0,0 +1,41 @@
+package store
+
+import (
+	"backend/internal/search"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSearchMessages_FiltersAndSnippet(t *testing.T) {
+	s, mock := newMockStore(t)
+	q, err := search.Parse(`deploy from:alice in:ops before:2026-03-01`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	before := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+
+	cols := append(append([]string{}, messageCols...), "name", "ts_headline")
+	mock.ExpectQuery(`SELECT (.+) FROM messages m (.+) LOWER\(username\) = \$4\) AND LOWER\(r.name\) = \$5 AND m.created_at < \$6 ORDER BY`).
+		WithArgs(int64(7), "deploy", headlineOptions, "alice", "ops", before, 21).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(int64(3), int64(1), int64(9), nil, nil, "<i>deploy</i> now", now, nil, nil, nil, nil,
+				"ops", "<i>"+search.StartSel+"deploy"+search.StopSel+"</i> now"))
+
+	hits, err := s.SearchMessages(context.Background(), 7, q, 21, nil, nil)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].RoomName != "ops" || hits[0].Message.ID != 3 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	if want := "&lt;i&gt;<mark>deploy</mark>&lt;/i&gt; now"; hits[0].Snippet != want {
+		t.Fatalf("snippet = %q, want %q", hits[0].Snippet, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+)
+
+func (s *Store) GetSettings(ctx context.Context, userID int64) (models.UserSettings, error) {
+	var st models.UserSettings
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT share_read_receipts FROM users WHERE id = $1
+	`, userID).Scan(&st.ShareReadReceipts)
+	return st, err
+}
+
+func (s *Store) UpdateSettings(ctx context.Context, userID int64, st models.UserSettings) (models.UserSettings, error) {
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE users SET share_read_receipts = $2 WHERE id = $1
+		RETURNING share_read_receipts
+	`, userID, st.ShareReadReceipts).Scan(&st.ShareReadReceipts)
+	return st, err
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// attachThreadSummaries fills ReplyCount/LastReplyAt on root messages.
+// Deleted replies are not counted.
+func (s *Store) attachThreadSummaries(ctx context.Context, items []models.Message) error {
+	if len(items) == 0 {
+		return nil
+	}
+	idx := make(map[int64]int, len(items))
+	ids := make([]int64, 0, len(items))
+	for i, m := range items {
+		idx[m.ID] = i
+		ids = append(ids, m.ID)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT parent_id, COUNT(*), MAX(created_at)
+		FROM messages
+		WHERE parent_id = ANY($1) AND deleted_at IS NULL
+		GROUP BY parent_id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			parentID int64
+			count    int
+			lastAt   sql.NullTime
+		)
+		if err := rows.Scan(&parentID, &count, &lastAt); err != nil {
+			return err
+		}
+		if i, ok := idx[parentID]; ok {
+			items[i].ReplyCount = count
+			if lastAt.Valid {
+				items[i].LastReplyAt = &lastAt.Time
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// ThreadSummary returns the current preview state of a thread root.
+func (s *Store) ThreadSummary(ctx context.Context, roomID, rootID int64) (models.ThreadSummary, error) {
+	ts := models.ThreadSummary{RoomID: roomID, MessageID: rootID}
+	var lastAt sql.NullTime
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*), MAX(created_at)
+		FROM messages
+		WHERE parent_id = $1 AND deleted_at IS NULL
+	`, rootID).Scan(&ts.ReplyCount, &lastAt)
+	if lastAt.Valid {
+		ts.LastReplyAt = &lastAt.Time
+	}
+	return ts, err
+}
+
+// RootMessageExists reports whether msgID is a thread root the viewer can see.
+func (s *Store) RootMessageExists(ctx context.Context, roomID, msgID, viewerID int64) (bool, error) {
+	var ok bool
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM messages
+			WHERE id = $1 AND room_id = $2 AND parent_id IS NULL
+			  AND `+roomVisible("$2", "$3")+`
+		)
+	`, msgID, roomID, viewerID).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestListReplies_OnlyThatThread(t *testing.T) {
+	s, mock := newMockStore(t)
+	ts := time.Now()
+
+	mock.ExpectQuery(`SELECT (.+) FROM messages (.+) AND parent_id = \$3 ORDER BY created_at DESC, id DESC LIMIT \$4`).
+		WithArgs(int64(1), int64(7), int64(5), 50).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(12, 1, 8, 5, nil, "second", ts, nil, nil, nil, nil).
+			AddRow(11, 1, 7, 5, nil, "first", ts.Add(-time.Second), nil, ts, nil, nil))
+	mock.ExpectQuery(`FROM message_reactions`).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "emoji", "count", "me"}))
+	mock.ExpectQuery(`FROM attachments`).WillReturnRows(sqlmock.NewRows(nil))
+	mock.ExpectQuery(`FROM polls`).WillReturnRows(sqlmock.NewRows(nil))
+
+	items, err := s.ListReplies(context.Background(), 1, 5, 7, 50, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != 12 || *items[0].ParentID != 5 {
+		t.Fatalf("unexpected replies %+v", items)
+	}
+	if !items[1].Deleted || items[1].Content != "" {
+		t.Fatalf("deleted reply should be a tombstone: %+v", items[1])
+	}
+	// replies carry no thread summary of their own
+	if items[0].ReplyCount != 0 || items[0].LastReplyAt != nil {
+		t.Fatalf("reply got a thread summary: %+v", items[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestThreadSummary_CountsLiveReplies(t *testing.T) {
+	s, mock := newMockStore(t)
+	last := time.Now()
+	mock.ExpectQuery(`SELECT COUNT\(\*\), MAX\(created_at\)\s+FROM messages\s+WHERE parent_id = \$1 AND deleted_at IS NULL`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(3, last))
+
+	ts, err := s.ThreadSummary(context.Background(), 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.RoomID != 1 || ts.MessageID != 5 || ts.ReplyCount != 3 || ts.LastReplyAt == nil || !ts.LastReplyAt.Equal(last) {
+		t.Fatalf("unexpected summary %+v", ts)
+	}
+}
+
+func TestThreadSummary_NoReplies(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM messages`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "max"}).AddRow(0, nil))
+
+	ts, err := s.ThreadSummary(context.Background(), 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.ReplyCount != 0 || ts.LastReplyAt != nil {
+		t.Fatalf("unexpected summary %+v", ts)
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"backend/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// CreateWorkspace creates a workspace owned by ownerID.
+func (s *Store) CreateWorkspace(ctx context.Context, name string, ownerID int64) (models.Workspace, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	defer tx.Rollback()
+
+	w := models.Workspace{Name: name, Role: models.RoleOwner}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO workspaces (name, created_by) VALUES ($1, $2)
+		RETURNING id, created_at
+	`, name, ownerID).Scan(&w.ID, &w.CreatedAt); err != nil {
+		return models.Workspace{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+	`, w.ID, ownerID, models.RoleOwner); err != nil {
+		return models.Workspace{}, err
+	}
+	return w, tx.Commit()
+}
+
+// ListWorkspaces returns the workspaces the user belongs to.
+func (s *Store) ListWorkspaces(ctx context.Context, userID int64) ([]models.Workspace, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT w.id, w.name, m.role, w.created_at
+		FROM workspace_members m
+		JOIN workspaces w ON w.id = m.workspace_id
+		WHERE m.user_id = $1
+		ORDER BY w.name ASC, w.id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Workspace
+	for rows.Next() {
+		var w models.Workspace
+		if err := rows.Scan(&w.ID, &w.Name, &w.Role, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// WorkspaceRole returns the user's role, or ErrNotFound for non-members.
+func (s *Store) WorkspaceRole(ctx context.Context, workspaceID, userID int64) (string, error) {
+	var role string
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+	`, workspaceID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	return role, err
+}
+
+// ListWorkspaceMembers returns members of a workspace the viewer belongs to.
+func (s *Store) ListWorkspaceMembers(ctx context.Context, workspaceID, viewerID int64) ([]models.WorkspaceMember, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		  AND EXISTS (SELECT 1 FROM workspace_members v WHERE v.workspace_id = $1 AND v.user_id = $2)
+		ORDER BY u.username ASC
+	`, workspaceID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.WorkspaceMember
+	for rows.Next() {
+		var m models.WorkspaceMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// RemoveWorkspaceMember takes targetID out of the workspace. Members may
+// leave on their own; owners and admins may remove members, and only the
+// owner may remove an admin. The owner cannot be removed.
+func (s *Store) RemoveWorkspaceMember(ctx context.Context, workspaceID, actorID, targetID int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var actorRole, targetRole sql.NullString
+	if err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2),
+			(SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $3)
+	`, workspaceID, actorID, targetID).Scan(&actorRole, &targetRole); err != nil {
+		return err
+	}
+	switch {
+	case !actorRole.Valid || !targetRole.Valid:
+		return ErrNotFound
+	case targetRole.String == models.RoleOwner:
+		return ErrForbidden
+	case actorID == targetID:
+	case actorRole.String != models.RoleOwner && actorRole.String != models.RoleAdmin:
+		return ErrForbidden
+	case targetRole.String == models.RoleAdmin && actorRole.String != models.RoleOwner:
+		return ErrForbidden
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2
+	`, workspaceID, targetID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateWorkspace_CreatorIsOwner(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO workspaces`).
+		WithArgs("acme", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, time.Now()))
+	mock.ExpectExec(`INSERT INTO workspace_members \(workspace_id, user_id, role\)`).
+		WithArgs(int64(3), int64(7), "owner").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w, err := s.CreateWorkspace(context.Background(), "acme", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.ID != 3 || w.Role != "owner" {
+		t.Fatalf("unexpected workspace %+v", w)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestWorkspaceRole(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`SELECT role FROM workspace_members`).
+		WithArgs(int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("admin"))
+	mock.ExpectQuery(`SELECT role FROM workspace_members`).
+		WithArgs(int64(3), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}))
+
+	if role, err := s.WorkspaceRole(context.Background(), 3, 7); err != nil || role != "admin" {
+		t.Fatalf("member: role=%q err=%v", role, err)
+	}
+	if _, err := s.WorkspaceRole(context.Background(), 3, 8); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("non-member: want ErrNotFound, got %v", err)
+	}
+}
+
+func TestListWorkspaceMembers_OnlyForMembers(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectQuery(`FROM workspace_members m(.+)WHERE m.workspace_id = \$1\s+AND EXISTS \(SELECT 1 FROM workspace_members v WHERE v.workspace_id = \$1 AND v.user_id = \$2\)`).
+		WithArgs(int64(3), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "role", "joined_at"}))
+
+	members, err := s.ListWorkspaceMembers(context.Background(), 3, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 0 {
+		t.Fatalf("outsider saw members %+v", members)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRemoveWorkspaceMember(t *testing.T) {
+	roles := func(actor, target any) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"actor", "target"}).AddRow(actor, target)
+	}
+	cases := []struct {
+		name          string
+		actor, target int64
+		rows          *sqlmock.Rows
+		want          error
+	}{
+		{"admin removes member", 7, 8, roles("admin", "member"), nil},
+		{"member leaves", 8, 8, roles("member", "member"), nil},
+		{"member removes member", 8, 9, roles("member", "member"), ErrForbidden},
+		{"admin removes admin", 7, 9, roles("admin", "admin"), ErrForbidden},
+		{"owner cannot be removed", 7, 1, roles("admin", "owner"), ErrForbidden},
+		{"target not a member", 7, 9, roles("admin", nil), ErrNotFound},
+	}
+	for _, c := range cases {
+		s, mock := newMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT role FROM workspace_members`).
+			WithArgs(int64(3), c.actor, c.target).
+			WillReturnRows(c.rows)
+		if c.want == nil {
+			mock.ExpectExec(`DELETE FROM workspace_members WHERE workspace_id = \$1 AND user_id = \$2`).
+				WithArgs(int64(3), c.target).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+
+		if err := s.RemoveWorkspaceMember(context.Background(), 3, c.actor, c.target); !errors.Is(err, c.want) {
+			t.Fatalf("%s: want %v, got %v", c.name, c.want, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: unmet sql expectations: %v", c.name, err)
+		}
+	}
+}
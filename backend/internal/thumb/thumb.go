@@ -0,0 +1,101 @@
+// Package thumb renders JPEG thumbnails of uploaded images with the standard
+// library decoders (PNG, JPEG, GIF).
+package thumb
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels bounds the decoded size so a tiny file cannot expand into a
+// huge bitmap.
+const MaxPixels = 40_000_000
+
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// Size reports the dimensions of an encoded image without decoding it.
+func Size(data []byte) (w, h int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// Make scales the image to fit in max×max (never upscaling) and encodes it
+// as JPEG, flattening transparency onto white.
+func Make(data []byte, max int) ([]byte, error) {
+	w, h, err := Size(data)
+	if err != nil {
+		return nil, err
+	}
+	if w*h > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	tw, th := w, h
+	if w > max || h > max {
+		if w >= h {
+			tw, th = max, h*max/w
+		} else {
+			tw, th = w*max/h, max
+		}
+	}
+	tw, th = maxInt(tw, 1), maxInt(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	scale(dst, src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scale box-filters src into dst, averaging the source pixels under each
+// destination pixel and compositing the result over white.
+func scale(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	db := dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/db.Dy()
+		y1 := maxInt(sb.Min.Y+(y+1)*sb.Dy()/db.Dy(), y0+1)
+		for x := 0; x < db.Dx(); x++ {
+			x0 := sb.Min.X + x*sb.Dx()/db.Dx()
+			x1 := maxInt(sb.Min.X+(x+1)*sb.Dx()/db.Dx(), x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// RGBA() is alpha-premultiplied, so "over white" adds the uncovered share
+			bg := 0xffff - a/n
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8((r/n + bg) >> 8),
+				G: uint8((g/n + bg) >> 8),
+				B: uint8((b/n + bg) >> 8),
+				A: 0xff,
+			})
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
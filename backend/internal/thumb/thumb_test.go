@@ -0,0 +1,51 @@
+package thumb
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMake_FitsBox(t *testing.T) {
+	out, err := Make(encodePNG(t, 800, 400), 320)
+	if err != nil {
+		t.Fatalf("make: %v", err)
+	}
+	w, h, err := Size(out)
+	if err != nil || w != 320 || h != 160 {
+		t.Fatalf("thumb %dx%d err=%v, want 320x160", w, h, err)
+	}
+}
+
+func TestMake_NoUpscale(t *testing.T) {
+	out, err := Make(encodePNG(t, 40, 90), 320)
+	if err != nil {
+		t.Fatalf("make: %v", err)
+	}
+	if w, h, _ := Size(out); w != 40 || h != 90 {
+		t.Fatalf("thumb %dx%d, want 40x90", w, h)
+	}
+}
+
+func TestMake_RejectsGarbage(t *testing.T) {
+	if _, err := Make([]byte("not an image"), 320); err == nil {
+		t.Fatalf("want error")
+	}
+}
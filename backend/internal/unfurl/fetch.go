@@ -0,0 +1,334 @@
+package unfurl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/html"
+)
+
+const (
+	fetchTimeout   = 5 * time.Second
+	maxPageBytes   = 512 << 10
+	maxOEmbedBytes = 64 << 10
+	maxRedirects   = 3
+
+	cacheTTL    = 24 * time.Hour
+	missTTL     = time.Hour // pages without a usable preview
+	cachePrefix = "unfurl:"
+)
+
+var ErrBlocked = errors.New("destination not allowed")
+
+// Fetcher builds previews and caches them in Redis.
+type Fetcher struct {
+	Client *http.Client
+	RDB    *redis.Client // nil: no cache
+}
+
+// NewFetcher returns a Fetcher whose client only reaches public addresses on
+// ports 80 and 443, with time, redirect and size limits.
+func NewFetcher(rdb *redis.Client) *Fetcher {
+	return &Fetcher{Client: safeClient(), RDB: rdb}
+}
+
+func safeClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: fetchTimeout,
+		// Control sees the resolved address, so DNS answers pointing at
+		// internal hosts are refused too, including after redirects.
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || (port != "80" && port != "443") || !publicAddr(ip) {
+				return ErrBlocked
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   fetchTimeout,
+			ResponseHeaderTimeout: fetchTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrBlocked
+			}
+			return nil
+		},
+	}
+}
+
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach private IPv4
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// publicAddr rejects loopback, private, link-local, multicast and other
+// special-purpose ranges.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Preview returns the cached or freshly fetched preview of rawURL, or nil
+// when the page has none. Fetch failures count as "none" and are cached
+// briefly so a broken link is not retried for every message.
+func (f *Fetcher) Preview(ctx context.Context, rawURL string) (*models.Embed, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, nil
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	key := cachePrefix + hex.EncodeToString(sum[:])
+
+	if f.RDB != nil {
+		cached, err := f.RDB.Get(ctx, key).Result()
+		switch {
+		case err == nil && cached == "":
+			return nil, nil
+		case err == nil:
+			var e models.Embed
+			if json.Unmarshal([]byte(cached), &e) == nil {
+				return &e, nil
+			}
+		case !errors.Is(err, redis.Nil):
+			return nil, err
+		}
+	}
+
+	e, err := f.fetch(ctx, u)
+	if err != nil {
+		log.Printf("[unfurl] %s: %v", rawURL, err)
+	}
+	if f.RDB != nil {
+		val, ttl := "", missTTL
+		if e != nil {
+			b, _ := json.Marshal(e)
+			val, ttl = string(b), cacheTTL
+		}
+		if err := f.RDB.Set(ctx, key, val, ttl).Err(); err != nil {
+			log.Printf("[unfurl] cache %s: %v", rawURL, err)
+		}
+	}
+	return e, nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, u *url.URL) (*models.Embed, error) {
+	body, final, err := f.get(ctx, u.String(), "text/html,application/xhtml+xml", maxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+	m := parseMeta(body)
+
+	e := &models.Embed{
+		URL:         u.String(),
+		Type:        m.get("og:type"),
+		Title:       m.first("og:title", "twitter:title", "title"),
+		Description: m.first("og:description", "twitter:description", "description"),
+		SiteName:    m.get("og:site_name"),
+		ImageURL:    resolve(final, m.first("og:image", "og:image:url", "twitter:image")),
+	}
+	if href := resolve(final, m.get("oembed")); href != "" && (e.Title == "" || e.ImageURL == "") {
+		if oe, err := f.oembed(ctx, href); err == nil {
+			e.Title = firstNonEmpty(e.Title, oe.Title)
+			e.SiteName = firstNonEmpty(e.SiteName, oe.ProviderName)
+			e.ImageURL = firstNonEmpty(e.ImageURL, resolve(final, oe.ThumbnailURL))
+			e.Type = firstNonEmpty(e.Type, oe.Type)
+		}
+	}
+	if e.Title == "" && e.Description == "" {
+		return nil, nil
+	}
+	e.Title = truncate(e.Title, 200)
+	e.Description = truncate(e.Description, 500)
+	e.SiteName = truncate(e.SiteName, 100)
+	return e, nil
+}
+
+type oembedResp struct {
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func (f *Fetcher) oembed(ctx context.Context, href string) (oembedResp, error) {
+	var oe oembedResp
+	body, _, err := f.get(ctx, href, "application/json", maxOEmbedBytes)
+	if err != nil {
+		return oe, err
+	}
+	return oe, json.Unmarshal(body, &oe)
+}
+
+// get fetches at most limit bytes of a 200 response whose media type
+// matches accept, returning the body and the URL after redirects.
+func (f *Fetcher) get(ctx context.Context, rawURL, accept string, limit int64) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "chatapp-unfurl/1.0")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("status %s", resp.Status)
+	}
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.Contains(accept, mt) || mt == "" {
+		return nil, nil, fmt.Errorf("unexpected content type %q", mt)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return body, resp.Request.URL, err
+}
+
+// meta maps og:*, twitter:*, description, title and oembed to their first value.
+type meta map[string]string
+
+func (m meta) get(k string) string { return m[k] }
+
+func (m meta) first(keys ...string) string {
+	for _, k := range keys {
+		if v := m[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseMeta scans the document head. A truncated body still yields whatever
+// came before the cut.
+func parseMeta(body []byte) meta {
+	m := meta{}
+	set := func(k, v string) {
+		v = strings.TrimSpace(v)
+		if _, ok := m[k]; !ok && v != "" {
+			m[k] = v
+		}
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return m
+		case html.TextToken:
+			if inTitle {
+				set("title", string(z.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return m
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[strings.ToLower(string(k))] = string(v)
+			}
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "meta":
+				key := strings.ToLower(firstNonEmpty(attrs["property"], attrs["name"]))
+				if strings.HasPrefix(key, "og:") || strings.HasPrefix(key, "twitter:") || key == "description" {
+					set(key, attrs["content"])
+				}
+			case "link":
+				if strings.EqualFold(attrs["type"], "application/json+oembed") {
+					set("oembed", attrs["href"])
+				}
+			case "body":
+				return m
+			}
+		}
+	}
+}
+
+// resolve makes ref absolute against base and keeps only http(s) URLs.
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		r = base.ResolveReference(r)
+	}
+	if r.Scheme != "http" && r.Scheme != "https" {
+		return ""
+	}
+	return r.String()
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
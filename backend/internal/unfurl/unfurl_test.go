@@ -0,0 +1,109 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestExtractURLs(t *testing.T) {
+	got := ExtractURLs("see https://a.example/x?y=1, and (http://b.example/). `https://code.example` https://a.example/x?y=1 https://c.example https://d.example", 3)
+	want := []string{"https://a.example/x?y=1", "http://b.example/", "https://c.example"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false, // cloud metadata
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+		"64:ff9b::a00:1":  false,
+	}
+	for in, want := range cases {
+		if got := publicAddr(netip.MustParseAddr(in)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSafeClient_RefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(nil)
+	if _, _, err := f.get(context.Background(), srv.URL, "text/html", maxPageBytes); err == nil {
+		t.Fatalf("want error fetching %s", srv.URL)
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	m := parseMeta([]byte(`<!doctype html><html><head>
+		<title> Fallback </title>
+		<meta property="og:title" content="Release notes">
+		<meta name="description" content="What changed">
+		<meta property="og:image" content="/img/cover.png">
+		<link rel="alternate" type="application/json+oembed" href="/oembed?u=1">
+		</head><body><meta property="og:title" content="ignored"></body></html>`))
+	if m.first("og:title", "title") != "Release notes" || m.get("title") != "Fallback" {
+		t.Fatalf("titles: %v", m)
+	}
+	if m.get("description") != "What changed" || m.get("og:image") != "/img/cover.png" || m.get("oembed") != "/oembed?u=1" {
+		t.Fatalf("meta: %v", m)
+	}
+}
+
+func TestPreview_FetchesOEmbedAndCaches(t *testing.T) {
+	var hits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><meta name="description" content="A clip">
+			<link type="application/json+oembed" href="/oembed"></head></html>`)
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"type":"video","title":"Demo","provider_name":"Tube","thumbnail_url":"/t.jpg"}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mr := miniredis.RunT(t)
+	// the test server is on loopback, so use a plain client here
+	f := &Fetcher{Client: srv.Client(), RDB: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+
+	for i := 0; i < 2; i++ {
+		e, err := f.Preview(context.Background(), srv.URL+"/page")
+		if err != nil || e == nil {
+			t.Fatalf("preview: %v %v", e, err)
+		}
+		if e.Title != "Demo" || e.Description != "A clip" || e.SiteName != "Tube" || e.Type != "video" || e.ImageURL != srv.URL+"/t.jpg" {
+			t.Fatalf("unexpected embed %+v", e)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("page fetched %d times, want 1 (cached)", hits.Load())
+	}
+}
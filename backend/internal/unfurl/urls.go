@@ -0,0 +1,39 @@
+// Package unfurl turns links in messages into previews (OpenGraph, oEmbed),
+// fetched in the background through an SSRF-safe HTTP client.
+package unfurl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MaxEmbeds is how many links of one message get a preview.
+const MaxEmbeds = 3
+
+var urlRe = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// ExtractURLs returns up to max distinct http(s) links outside `code` spans,
+// in order of appearance, without trailing punctuation.
+func ExtractURLs(content string, max int) []string {
+	var out []string
+	seen := map[string]bool{}
+	for i, part := range strings.Split(content, "`") {
+		if i%2 == 1 { // inside a code span
+			continue
+		}
+		for _, raw := range urlRe.FindAllString(part, -1) {
+			raw = strings.TrimRight(raw, ".,;:!?)]}*_~")
+			u, err := url.Parse(raw)
+			if err != nil || u.Host == "" || seen[raw] {
+				continue
+			}
+			seen[raw] = true
+			out = append(out, raw)
+			if len(out) == max {
+				return out
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package unfurl
+
+import (
+	"backend/internal/models"
+	"backend/internal/store"
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const perMessageTimeout = 15 * time.Second
+
+// Worker unfurls messages off the request path: Enqueue never blocks, and
+// Run's goroutines fetch previews, store them and call Notify.
+type Worker struct {
+	Fetcher *Fetcher
+	Store   *store.Store
+	Notify  func(models.Message) // e.g. Hub.BroadcastEmbedsUpdated
+
+	queue chan models.Message
+}
+
+func NewWorker(f *Fetcher, st *store.Store, notify func(models.Message), queueSize int) *Worker {
+	return &Worker{Fetcher: f, Store: st, Notify: notify, queue: make(chan models.Message, queueSize)}
+}
+
+// Enqueue schedules m if its content has links. A full queue drops the
+// message: previews are best effort. Ephemeral messages are never previewed,
+// so their links don't reach third-party sites. Safe on a nil Worker.
+func (w *Worker) Enqueue(m models.Message) {
+	if w == nil || m.ExpiresAt != nil || len(ExtractURLs(m.Content, 1)) == 0 {
+		return
+	}
+	select {
+	case w.queue <- m:
+	default:
+		log.Printf("[unfurl] queue full, skipping message %d", m.ID)
+	}
+}
+
+// Run starts n workers that stop when ctx is cancelled.
+func (w *Worker) Run(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case m := <-w.queue:
+					w.process(ctx, m)
+				}
+			}
+		}()
+	}
+}
+
+func (w *Worker) process(ctx context.Context, m models.Message) {
+	ctx, cancel := context.WithTimeout(ctx, perMessageTimeout)
+	defer cancel()
+
+	var embeds []models.Embed
+	for _, u := range ExtractURLs(m.Content, MaxEmbeds) {
+		e, err := w.Fetcher.Preview(ctx, u)
+		if err != nil {
+			log.Printf("[unfurl] preview %s: %v", u, err)
+			continue
+		}
+		if e != nil {
+			embeds = append(embeds, *e)
+		}
+	}
+	if len(embeds) == 0 {
+		return
+	}
+	updated, err := w.Store.SetMessageEmbeds(ctx, m.ID, m.Content, embeds)
+	if errors.Is(err, store.ErrNotFound) {
+		return // edited or deleted meanwhile
+	}
+	if err != nil {
+		log.Printf("[unfurl] save message %d: %v", m.ID, err)
+		return
+	}
+	if w.Notify != nil {
+		w.Notify(updated)
+	}
+}
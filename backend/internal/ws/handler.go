@@ -1,10 +1,15 @@
 package ws
 
 import (
+	"backend/internal/msgsvc"
+	"backend/internal/presence"
 	"backend/internal/store"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,17 +17,27 @@ import (
 )
 
 type Handler struct {
-	Store *store.Store
-	Hub   *Hub
+	Store    *store.Store
+	Hub      *Hub
+	Presence *presence.Tracker // nil disables presence tracking
+	Messages *msgsvc.Service   // create, edit and delete pipeline
 }
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true }, // MVP
 }
 
-type wsConn struct{ *websocket.Conn }
+// wsConn serializes writes: the hub, per-user sends and the read loop all write.
+type wsConn struct {
+	*websocket.Conn
+	mu *sync.Mutex
+}
 
-func (c wsConn) WriteJSON(v any) error { return c.Conn.WriteJSON(v) }
+func (c wsConn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
 
 func (h *Handler) Handle(c *gin.Context) {
 	uidAny, ok := c.Get("userID")
@@ -38,15 +53,30 @@ func (h *Handler) Handle(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room_id", "code": "VALIDATION_FAILED"})
 		return
 	}
+	allowed, err := h.Store.CanAccessRoom(c.Request.Context(), roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db error", "code": "INTERNAL"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found", "code": "NOT_FOUND"})
+		return
+	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
-	ws := wsConn{Conn: conn}
+	ws := wsConn{Conn: conn, mu: &sync.Mutex{}}
 	h.Hub.Join(roomID, ws)
+	h.Hub.Register(userID, ws)
+	stopPresence := h.trackPresence(c.Request.Context(), userID, roomID)
+	typingLimit := newRateLimiter(1, 3) // typing packets: 1/s, bursts of 3
 	defer func() {
+		h.Hub.TypingStop(roomID, userID)
+		h.Hub.Unregister(userID, ws)
 		h.Hub.Leave(roomID, ws)
+		stopPresence()
 		_ = conn.Close()
 	}()
 
@@ -67,22 +97,183 @@ func (h *Handler) Handle(c *gin.Context) {
 		switch packet.Type {
 		case "message.create":
 			var p struct {
-				Content string `json:"content"`
+				Content       string  `json:"content"`
+				ParentID      *int64  `json:"parent_id"`
+				ReplyToID     *int64  `json:"reply_to_id"`
+				AttachmentIDs []int64 `json:"attachment_ids"`
+				TTLSeconds    int     `json:"ttl_seconds"`
+				Poll          *struct {
+					Options   []string   `json:"options"`
+					Multiple  bool       `json:"multiple"`
+					Anonymous bool       `json:"anonymous"`
+					ClosesAt  *time.Time `json:"closes_at"`
+				} `json:"poll"`
 			}
 			if err := json.Unmarshal(packet.Data, &p); err != nil {
 				continue
 			}
-			if l := len([]rune(p.Content)); l < 1 || l > 2000 {
-				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": "content length 1..2000"})
+			in := msgsvc.Input{
+				RoomID: roomID, UserID: userID, Content: p.Content,
+				ParentID: p.ParentID, ReplyToID: p.ReplyToID,
+				AttachmentIDs: p.AttachmentIDs,
+				TTLSeconds:    p.TTLSeconds,
+			}
+			if p.Poll != nil {
+				in.Poll = &store.NewPoll{Options: p.Poll.Options, Multiple: p.Poll.Multiple, Anonymous: p.Poll.Anonymous, ClosesAt: p.Poll.ClosesAt}
+			}
+			_, err := h.Messages.Create(c.Request.Context(), in)
+			var e *msgsvc.Error
+			if errors.As(err, &e) {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": e.Msg})
 				continue
 			}
-			msg, err := h.Store.CreateMessage(c.Request.Context(), roomID, userID, p.Content)
 			if err != nil {
+				log.Printf("[ws] insert error room_id=%d user_id=%d: %v", roomID, userID, err)
 				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": "insert error"})
 				continue
 			}
-			h.Hub.BroadcastMessage(msg)
+		case "message.update":
+			var p struct {
+				ID      int64  `json:"id"`
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal(packet.Data, &p); err != nil || p.ID <= 0 {
+				continue
+			}
+			_, err := h.Messages.Edit(c.Request.Context(), roomID, p.ID, userID, p.Content)
+			var e *msgsvc.Error
+			if errors.As(err, &e) {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": e.Msg})
+				continue
+			}
+			if err != nil {
+				log.Printf("[ws] update error room_id=%d msg_id=%d user_id=%d: %v", roomID, p.ID, userID, err)
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": "update error"})
+			}
+		case "message.delete":
+			var p struct {
+				ID int64 `json:"id"`
+			}
+			if err := json.Unmarshal(packet.Data, &p); err != nil || p.ID <= 0 {
+				continue
+			}
+			_, err := h.Messages.Delete(c.Request.Context(), roomID, p.ID, userID)
+			var e *msgsvc.Error
+			if errors.As(err, &e) {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": e.Msg})
+				continue
+			}
+			if err != nil {
+				log.Printf("[ws] delete error room_id=%d msg_id=%d user_id=%d: %v", roomID, p.ID, userID, err)
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": "delete error"})
+			}
+		case "reaction.add", "reaction.remove":
+			var p struct {
+				MessageID int64  `json:"message_id"`
+				Emoji     string `json:"emoji"`
+			}
+			if err := json.Unmarshal(packet.Data, &p); err != nil || p.MessageID <= 0 {
+				continue
+			}
+			add := packet.Type == "reaction.add"
+			var changed bool
+			if add {
+				changed, err = h.Store.AddReaction(c.Request.Context(), roomID, p.MessageID, userID, p.Emoji)
+			} else {
+				changed, err = h.Store.RemoveReaction(c.Request.Context(), roomID, p.MessageID, userID, p.Emoji)
+			}
+			if err != nil {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": reactionErrorText(err)})
+				continue
+			}
+			if changed {
+				h.Hub.BroadcastReaction(roomID, p.MessageID, userID, p.Emoji, add)
+			}
+		case "poll.vote", "poll.unvote":
+			var p struct {
+				MessageID int64 `json:"message_id"`
+				OptionID  int64 `json:"option_id"`
+			}
+			if err := json.Unmarshal(packet.Data, &p); err != nil || p.MessageID <= 0 || p.OptionID <= 0 {
+				continue
+			}
+			poll, changed, err := h.Store.Vote(c.Request.Context(), roomID, p.MessageID, p.OptionID, userID, packet.Type == "poll.vote")
+			if err != nil {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": voteErrorText(err)})
+				continue
+			}
+			if changed {
+				h.Hub.BroadcastPollUpdated(roomID, poll)
+			}
+			// the voter's own view, with Me set, after the shared tallies
+			h.Hub.SendToUser(userID, "poll.voted", poll)
+		case "room.read":
+			var p struct {
+				MessageID *int64 `json:"message_id"`
+			}
+			if len(packet.Data) > 0 {
+				if err := json.Unmarshal(packet.Data, &p); err != nil {
+					continue
+				}
+			}
+			rm, moved, err := h.Store.MarkRoomRead(c.Request.Context(), roomID, userID, p.MessageID)
+			if errors.Is(err, store.ErrNotFound) {
+				err = &msgsvc.Error{Code: msgsvc.CodeNotFound, Msg: "message not found"}
+			}
+			var e *msgsvc.Error
+			if errors.As(err, &e) {
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": e.Msg})
+				continue
+			}
+			if err != nil {
+				log.Printf("[ws] read error room_id=%d user_id=%d: %v", roomID, userID, err)
+				_ = ws.WriteJSON(gin.H{"type": "system.ack", "error": "read error"})
+				continue
+			}
+			if moved {
+				h.Hub.SendToUser(userID, "room.read", rm)
+				if st, err := h.Store.GetSettings(c.Request.Context(), userID); err == nil && st.ShareReadReceipts {
+					h.Hub.QueueReceipt(rm)
+				}
+			}
+		case "typing.start", "typing.stop":
+			if !typingLimit.allow(time.Now()) {
+				continue
+			}
+			if packet.Type == "typing.start" {
+				h.Hub.TypingStart(roomID, userID)
+			} else {
+				h.Hub.TypingStop(roomID, userID)
+			}
 		default:
 		}
 	}
 }
+
+func reactionErrorText(err error) string {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return "message not found"
+	case errors.Is(err, store.ErrInvalidEmoji):
+		return "invalid emoji"
+	case errors.Is(err, store.ErrTooManyReactions):
+		return "too many reactions"
+	default:
+		return "reaction error"
+	}
+}
+
+func voteErrorText(err error) string {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return "poll not found"
+	case errors.Is(err, store.ErrForbidden):
+		return "you cannot vote in this room"
+	case errors.Is(err, store.ErrPollClosed):
+		return "poll closed"
+	case errors.Is(err, store.ErrInvalidOption):
+		return "invalid option"
+	default:
+		return "vote error"
+	}
+}
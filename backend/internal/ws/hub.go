@@ -2,6 +2,10 @@ package ws
 
 import (
 	"backend/internal/models"
+	"backend/internal/presence"
+	"context"
+	"log"
+	"slices"
 	"sync"
 	"time"
 )
@@ -14,10 +18,29 @@ type Client interface {
 type Hub struct {
 	mu      sync.RWMutex
 	clients map[int64]map[Client]struct{} // roomID -> set
+	users   map[int64]map[Client]struct{} // userID -> live connections, any room
+	owners  map[Client]int64              // connection -> userID
+
+	receipts *receiptThrottle
+	typing   *typingTracker
+
+	id    string // instance id, tags relayed envelopes
+	relay Relay  // nil: single instance
+
+	presence *presence.Tracker // nil: only local subscribers count as online
 }
 
 func NewHub() *Hub {
-	return &Hub{clients: make(map[int64]map[Client]struct{})}
+	h := &Hub{
+		clients: make(map[int64]map[Client]struct{}),
+		users:   make(map[int64]map[Client]struct{}),
+		owners:  make(map[Client]int64),
+		id:      newInstanceID(),
+
+		receipts: newReceiptThrottle(ReceiptInterval),
+	}
+	h.typing = newTypingTracker(TypingTTL, h.broadcastTyping)
+	return h
 }
 
 type WSMessage struct {
@@ -46,19 +69,241 @@ func (h *Hub) Leave(roomID int64, c Client) {
 	}
 }
 
-func (h *Hub) BroadcastMessage(m models.Message) {
+// Register ties a connection to its user so per-user events reach it
+// whatever room it is subscribed to.
+func (h *Hub) Register(userID int64, c Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.users[userID] == nil {
+		h.users[userID] = make(map[Client]struct{})
+	}
+	h.users[userID][c] = struct{}{}
+	h.owners[c] = userID
+}
+
+func (h *Hub) Unregister(userID int64, c Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if set := h.users[userID]; set != nil {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(h.users, userID)
+		}
+	}
+	delete(h.owners, c)
+}
+
+// SendToUser delivers an event to every live connection of the user.
+func (h *Hub) SendToUser(userID int64, typ string, data any) {
+	h.dispatch(envelope{UserID: userID, Msg: WSMessage{Type: typ, Data: data, TS: time.Now().UTC()}})
+}
+
+// DisconnectUser closes every connection of the user on every instance, so
+// room subscriptions are re-checked when the client reconnects. Call it after
+// the user loses access to rooms they may be subscribed to.
+func (h *Hub) DisconnectUser(userID int64) {
+	h.dispatch(envelope{UserID: userID, Disconnect: true, Msg: WSMessage{Type: "system.disconnect", TS: time.Now().UTC()}})
+}
+
+// RoomUserIDs returns the distinct users currently subscribed to the room.
+func (h *Hub) RoomUserIDs(roomID int64) []int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	seen := make(map[int64]bool)
+	var out []int64
+	for cli := range h.clients[roomID] {
+		if uid, ok := h.owners[cli]; ok && !seen[uid] {
+			seen[uid] = true
+			out = append(out, uid)
+		}
+	}
+	return out
+}
+
+// UsePresence lets OnlineUserIDs see subscribers on other instances.
+func (h *Hub) UsePresence(t *presence.Tracker) {
+	h.presence = t
+}
+
+// OnlineUserIDs returns the users connected to the room on any instance.
+// If presence is unavailable it falls back to the local subscribers.
+func (h *Hub) OnlineUserIDs(ctx context.Context, roomID int64) []int64 {
+	local := h.RoomUserIDs(roomID)
+	if h.presence == nil {
+		return local
+	}
+	remote, err := h.presence.RoomUsers(ctx, roomID)
+	if err != nil {
+		log.Printf("[hub] online users room_id=%d: %v", roomID, err)
+		return local
+	}
+	for _, id := range local {
+		if !slices.Contains(remote, id) {
+			remote = append(remote, id)
+		}
+	}
+	return remote
+}
+
+// Broadcast sends an event to every client subscribed to the room.
+func (h *Hub) Broadcast(roomID int64, typ string, data any) {
+	h.dispatch(envelope{RoomID: roomID, Msg: WSMessage{Type: typ, Data: data, TS: time.Now().UTC()}})
+}
+
+// BroadcastExceptUser is Broadcast minus every connection of userID.
+func (h *Hub) BroadcastExceptUser(roomID, userID int64, typ string, data any) {
+	h.dispatch(envelope{RoomID: roomID, ExceptUserID: userID, Msg: WSMessage{Type: typ, Data: data, TS: time.Now().UTC()}})
+}
+
+// dispatch delivers locally and, when distributed, to the other instances.
+func (h *Hub) dispatch(env envelope) {
+	h.deliver(env)
+	if h.relay != nil {
+		env.Origin = h.id
+		h.publish(env)
+	}
+}
+
+func (h *Hub) deliver(env envelope) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if env.UserID != 0 {
+		for cli := range h.users[env.UserID] {
+			_ = cli.WriteJSON(env.Msg)
+			if env.Disconnect {
+				_ = cli.Close()
+			}
+		}
+		return
+	}
+	for cli := range h.clients[env.RoomID] {
+		if env.ExceptUserID != 0 && h.owners[cli] == env.ExceptUserID {
+			continue
+		}
+		_ = cli.WriteJSON(env.Msg)
+	}
+}
+
+func messagePayload(m models.Message) map[string]any {
 	payload := map[string]any{
 		"id":         m.ID,
 		"room_id":    m.RoomID,
 		"user_id":    m.UserID,
 		"content":    m.Content,
+		"html":       m.HTML,
 		"created_at": m.CreatedAt.UTC().Format(time.RFC3339),
 	}
-	msg := WSMessage{Type: "message.create", Data: payload, TS: time.Now().UTC()}
+	if m.ParentID != nil {
+		payload["parent_id"] = *m.ParentID
+	}
+	if m.ReplyTo != nil {
+		payload["reply_to"] = m.ReplyTo
+	}
+	if m.EditedAt != nil {
+		payload["edited_at"] = m.EditedAt.UTC().Format(time.RFC3339)
+	}
+	if m.ExpiresAt != nil {
+		payload["expires_at"] = m.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if len(m.Attachments) > 0 {
+		payload["attachments"] = m.Attachments
+	}
+	if m.Poll != nil {
+		payload["poll"] = m.Poll.Shared()
+	}
+	return payload
+}
 
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	for cli := range h.clients[m.RoomID] {
-		_ = cli.WriteJSON(msg)
+func (h *Hub) BroadcastMessage(m models.Message) {
+	h.Broadcast(m.RoomID, "message.create", messagePayload(m))
+}
+
+func (h *Hub) BroadcastMessageUpdated(m models.Message) {
+	h.Broadcast(m.RoomID, "message.updated", messagePayload(m))
+}
+
+func (h *Hub) BroadcastMessageDeleted(m models.Message) {
+	h.Broadcast(m.RoomID, "message.deleted", map[string]any{
+		"id":      m.ID,
+		"room_id": m.RoomID,
+	})
+}
+
+// BroadcastEmbedsUpdated sends the link previews fetched after m was sent.
+func (h *Hub) BroadcastEmbedsUpdated(m models.Message) {
+	h.Broadcast(m.RoomID, "message.embeds_updated", map[string]any{
+		"id":      m.ID,
+		"room_id": m.RoomID,
+		"embeds":  m.Embeds,
+	})
+}
+
+// BroadcastReaction sends reaction.added or reaction.removed.
+func (h *Hub) BroadcastReaction(roomID, msgID, userID int64, emoji string, added bool) {
+	typ := "reaction.removed"
+	if added {
+		typ = "reaction.added"
+	}
+	h.Broadcast(roomID, typ, map[string]any{
+		"message_id": msgID,
+		"room_id":    roomID,
+		"user_id":    userID,
+		"emoji":      emoji,
+	})
+}
+
+// BroadcastPollUpdated sends live tallies after a vote. The viewer-specific
+// Me flags are cleared; voters learn their own choices from their request.
+func (h *Hub) BroadcastPollUpdated(roomID int64, p models.Poll) {
+	h.Broadcast(roomID, "poll.updated", p.Shared())
+}
+
+// BroadcastThreadUpdated lets clients refresh a thread preview live.
+func (h *Hub) BroadcastThreadUpdated(t models.ThreadSummary) {
+	h.Broadcast(t.RoomID, "thread.updated", t)
+}
+
+// NotifyMentions pushes mention.created to each mentioned user.
+func (h *Hub) NotifyMentions(ms []models.Mention) {
+	for _, m := range ms {
+		h.SendToUser(m.UserID, "mention.created", m)
 	}
 }
+
+// BroadcastPresence sends presence.changed to each of the rooms.
+func (h *Hub) BroadcastPresence(roomIDs []int64, p models.Presence) {
+	for _, roomID := range roomIDs {
+		h.Broadcast(roomID, "presence.changed", p)
+	}
+}
+
+// BroadcastPinned sends message.pinned with who pinned it and when.
+func (h *Hub) BroadcastPinned(p models.Pin) {
+	h.Broadcast(p.Message.RoomID, "message.pinned", map[string]any{
+		"message_id":         p.Message.ID,
+		"room_id":            p.Message.RoomID,
+		"message":            messagePayload(p.Message),
+		"pinned_by":          p.PinnedBy,
+		"pinned_by_username": p.PinnedByUsername,
+		"pinned_at":          p.PinnedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (h *Hub) BroadcastUnpinned(roomID, msgID, userID int64) {
+	h.Broadcast(roomID, "message.unpinned", map[string]any{
+		"message_id":  msgID,
+		"room_id":     roomID,
+		"unpinned_by": userID,
+	})
+}
+
+// SendScheduledFailed tells the author, on their live connections, that a
+// scheduled message could not be posted and why (sm.Error).
+func (h *Hub) SendScheduledFailed(sm models.ScheduledMessage) {
+	h.SendToUser(sm.UserID, "scheduled.failed", sm)
+}
+
+// SendBookmarkReminder pushes bookmark.reminder to the user's live connections.
+func (h *Hub) SendBookmarkReminder(b models.Bookmark) {
+	h.SendToUser(b.UserID, "bookmark.reminder", b)
+}
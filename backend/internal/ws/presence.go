@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"backend/internal/models"
+	"backend/internal/presence"
+	"context"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var connSeq atomic.Uint64
+
+// trackPresence registers the connection with the presence tracker and keeps
+// it alive until the returned stop func runs. presence.changed goes out when
+// the user's first connection opens and when their last one closes.
+func (h *Handler) trackPresence(ctx context.Context, userID, roomID int64) (stop func()) {
+	if h.Presence == nil {
+		return func() {}
+	}
+	connID := h.Hub.id + ":" + strconv.FormatUint(connSeq.Add(1), 10)
+	if status, changed, err := h.Presence.Connect(ctx, userID, connID); err != nil {
+		log.Printf("[presence] connect user %d: %v", userID, err)
+	} else if changed {
+		h.announcePresence(ctx, userID, roomID, status)
+	}
+	if err := h.Presence.EnterRoom(ctx, roomID, userID, connID); err != nil {
+		log.Printf("[presence] enter room %d user %d: %v", roomID, userID, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(presence.HeartbeatInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := h.Presence.Heartbeat(context.Background(), userID, connID); err != nil {
+					log.Printf("[presence] heartbeat user %d: %v", userID, err)
+				}
+				if err := h.Presence.EnterRoom(context.Background(), roomID, userID, connID); err != nil {
+					log.Printf("[presence] room heartbeat room %d user %d: %v", roomID, userID, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		// the request context may already be gone once the socket closed
+		ctx := context.Background()
+		if err := h.Presence.LeaveRoom(ctx, roomID, userID, connID); err != nil {
+			log.Printf("[presence] leave room %d user %d: %v", roomID, userID, err)
+		}
+		changed, err := h.Presence.Disconnect(ctx, userID, connID)
+		if err != nil {
+			log.Printf("[presence] disconnect user %d: %v", userID, err)
+			return
+		}
+		if changed {
+			h.announcePresence(ctx, userID, roomID, presence.StatusOffline)
+		}
+	}
+}
+
+// announcePresence pushes the status to the user's member rooms and the
+// room this connection is subscribed to.
+func (h *Handler) announcePresence(ctx context.Context, userID, roomID int64, status string) {
+	rooms, err := h.Store.MemberRoomIDs(ctx, userID)
+	if err != nil {
+		log.Printf("[presence] rooms of user %d: %v", userID, err)
+	}
+	seen := false
+	for _, id := range rooms {
+		if id == roomID {
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		rooms = append(rooms, roomID)
+	}
+	h.Hub.BroadcastPresence(rooms, models.Presence{UserID: userID, Status: status})
+}
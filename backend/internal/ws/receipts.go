@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"backend/internal/models"
+	"sync"
+	"time"
+)
+
+// ReceiptInterval is how often receipt.updated may fire per (room, user).
+const ReceiptInterval = 2 * time.Second
+
+type receiptKey struct{ roomID, userID int64 }
+
+// receiptThrottle coalesces read markers: the first marker in a window arms a
+// timer, later ones only replace the pending marker, and the timer sends the
+// latest one. A fast reader costs one event per interval instead of one per
+// message.
+type receiptThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	pending  map[receiptKey]models.ReadMarker
+}
+
+func newReceiptThrottle(interval time.Duration) *receiptThrottle {
+	return &receiptThrottle{interval: interval, pending: make(map[receiptKey]models.ReadMarker)}
+}
+
+func (t *receiptThrottle) queue(rm models.ReadMarker, send func(models.ReadMarker)) {
+	k := receiptKey{rm.RoomID, rm.UserID}
+
+	t.mu.Lock()
+	_, armed := t.pending[k]
+	t.pending[k] = rm
+	t.mu.Unlock()
+	if armed {
+		return
+	}
+
+	time.AfterFunc(t.interval, func() {
+		t.mu.Lock()
+		latest := t.pending[k]
+		delete(t.pending, k)
+		t.mu.Unlock()
+		send(latest)
+	})
+}
+
+// QueueReceipt schedules a coalesced receipt.updated for the marker's room.
+func (h *Hub) QueueReceipt(rm models.ReadMarker) {
+	h.receipts.queue(rm, func(latest models.ReadMarker) {
+		h.Broadcast(latest.RoomID, "receipt.updated", latest)
+	})
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"backend/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReceiptThrottle_Coalesces(t *testing.T) {
+	th := newReceiptThrottle(20 * time.Millisecond)
+
+	var (
+		mu   sync.Mutex
+		sent []models.ReadMarker
+	)
+	send := func(rm models.ReadMarker) {
+		mu.Lock()
+		sent = append(sent, rm)
+		mu.Unlock()
+	}
+
+	for id := int64(1); id <= 5; id++ {
+		th.queue(models.ReadMarker{RoomID: 1, UserID: 7, MessageID: id}, send)
+	}
+	th.queue(models.ReadMarker{RoomID: 1, UserID: 8, MessageID: 3}, send)
+
+	time.Sleep(60 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 2 {
+		t.Fatalf("want 2 coalesced receipts, got %d: %+v", len(sent), sent)
+	}
+	for _, rm := range sent {
+		if rm.UserID == 7 && rm.MessageID != 5 {
+			t.Fatalf("want latest marker 5 for user 7, got %d", rm.MessageID)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Relay fans hub events out to the other backend instances.
+type Relay interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Subscribe calls fn for every payload published by any instance until ctx ends.
+	Subscribe(ctx context.Context, fn func(payload []byte))
+}
+
+// envelope is one hub event on the wire: a room broadcast (RoomID, optionally
+// skipping ExceptUserID's connections) or a per-user send (UserID). With
+// Disconnect set, the user's connections are closed after Msg is sent.
+type envelope struct {
+	Origin       string    `json:"origin"`
+	RoomID       int64     `json:"room_id,omitempty"`
+	UserID       int64     `json:"user_id,omitempty"`
+	ExceptUserID int64     `json:"except_user_id,omitempty"`
+	Disconnect   bool      `json:"disconnect,omitempty"`
+	Msg          WSMessage `json:"msg"`
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UseRelay makes the hub distributed: local events are published to r and
+// events from other instances are delivered to local clients.
+func (h *Hub) UseRelay(ctx context.Context, r Relay) {
+	h.relay = r
+	go r.Subscribe(ctx, func(payload []byte) {
+		var env envelope
+		if err := json.Unmarshal(payload, &env); err != nil {
+			log.Printf("[hub] bad relay payload: %v", err)
+			return
+		}
+		if env.Origin == h.id {
+			return
+		}
+		h.deliver(env)
+	})
+}
+
+func (h *Hub) publish(env envelope) {
+	b, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("[hub] relay marshal %s: %v", env.Msg.Type, err)
+		return
+	}
+	if err := h.relay.Publish(context.Background(), b); err != nil {
+		log.Printf("[hub] relay publish %s: %v", env.Msg.Type, err)
+	}
+}
+
+// RedisRelay is a Relay over one Redis pub/sub channel.
+type RedisRelay struct {
+	RDB     *redis.Client
+	Channel string
+}
+
+func (r *RedisRelay) Publish(ctx context.Context, payload []byte) error {
+	return r.RDB.Publish(ctx, r.Channel, payload).Err()
+}
+
+func (r *RedisRelay) Subscribe(ctx context.Context, fn func(payload []byte)) {
+	sub := r.RDB.Subscribe(ctx, r.Channel)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-ch:
+			if !ok {
+				return
+			}
+			fn([]byte(m.Payload))
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ws
+
+import (
+	"backend/internal/presence"
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeClient struct {
+	mu     sync.Mutex
+	msgs   []WSMessage
+	closed bool
+}
+
+func (f *fakeClient) WriteJSON(v any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, v.(WSMessage))
+	return nil
+}
+
+func (f *fakeClient) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeClient) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *fakeClient) types() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, m := range f.msgs {
+		out = append(out, m.Type)
+	}
+	return out
+}
+
+func TestRedisRelay_CrossInstance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newHub := func() *Hub {
+		h := NewHub()
+		h.UseRelay(ctx, &RedisRelay{RDB: redis.NewClient(&redis.Options{Addr: mr.Addr()}), Channel: "chat:hub"})
+		return h
+	}
+	a, b := newHub(), newHub()
+	time.Sleep(50 * time.Millisecond) // let subscriptions attach
+
+	typer, local := &fakeClient{}, &fakeClient{}
+	remote, remoteTyper := &fakeClient{}, &fakeClient{}
+	a.Join(1, typer)
+	a.Register(7, typer)
+	a.Join(1, local)
+	a.Register(8, local)
+	b.Join(1, remote)
+	b.Register(9, remote)
+	b.Join(1, remoteTyper) // same user on another instance
+	b.Register(7, remoteTyper)
+
+	a.TypingStart(1, 7)
+
+	deadline := time.Now().Add(time.Second)
+	for len(remote.types()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := remote.types(); len(got) != 1 || got[0] != "typing.start" {
+		t.Fatalf("remote instance: want [typing.start], got %v", got)
+	}
+	if got := local.types(); len(got) != 1 {
+		t.Fatalf("local member: want 1 event, got %v", got)
+	}
+	if got := typer.types(); len(got) != 0 {
+		t.Fatalf("sender must not get its own typing event, got %v", got)
+	}
+	if got := remoteTyper.types(); len(got) != 0 {
+		t.Fatalf("sender's other devices must not get its typing event, got %v", got)
+	}
+}
+
+func TestOnlineUserIDs_SpansInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	tracker := &presence.Tracker{RDB: redis.NewClient(&redis.Options{Addr: mr.Addr()})}
+
+	a := NewHub()
+	a.UsePresence(tracker)
+	local := &fakeClient{}
+	a.Join(1, local)
+	a.Register(7, local)
+	// user 9 is connected to room 1 through another instance
+	if err := tracker.EnterRoom(ctx, 1, 9, "b:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := a.OnlineUserIDs(ctx, 1)
+	slices.Sort(got)
+	if !slices.Equal(got, []int64{7, 9}) {
+		t.Fatalf("online = %v, want [7 9]", got)
+	}
+}
+
+func TestDisconnectUser_ClosesConnectionsOnEveryInstance(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newHub := func() *Hub {
+		h := NewHub()
+		h.UseRelay(ctx, &RedisRelay{RDB: redis.NewClient(&redis.Options{Addr: mr.Addr()}), Channel: "chat:hub"})
+		return h
+	}
+	a, b := newHub(), newHub()
+	time.Sleep(50 * time.Millisecond) // let subscriptions attach
+
+	removed, remote, other := &fakeClient{}, &fakeClient{}, &fakeClient{}
+	a.Join(1, removed)
+	a.Register(7, removed)
+	b.Join(2, remote) // same user, another room and instance
+	b.Register(7, remote)
+	a.Join(1, other)
+	a.Register(8, other)
+
+	a.DisconnectUser(7)
+
+	deadline := time.Now().Add(time.Second)
+	for !remote.isClosed() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !removed.isClosed() || !remote.isClosed() {
+		t.Fatalf("removed user still connected: local=%v remote=%v", removed.isClosed(), remote.isClosed())
+	}
+	if other.isClosed() || len(other.types()) != 0 {
+		t.Fatalf("other member affected: closed=%v events=%v", other.isClosed(), other.types())
+	}
+}
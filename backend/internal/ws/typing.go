@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+// TypingTTL ends a typing indicator when no typing.start refresh or
+// typing.stop arrives in time (closed tab, lost connection).
+const TypingTTL = 6 * time.Second
+
+type typingKey struct{ roomID, userID int64 }
+
+// typingTracker keeps typing state in memory only, it never touches Postgres.
+type typingTracker struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	timers map[typingKey]*time.Timer
+	emit   func(roomID, userID int64, typing bool)
+}
+
+func newTypingTracker(ttl time.Duration, emit func(roomID, userID int64, typing bool)) *typingTracker {
+	return &typingTracker{ttl: ttl, timers: make(map[typingKey]*time.Timer), emit: emit}
+}
+
+// start emits typing.start on the first call and pushes expiry back on refreshes.
+func (t *typingTracker) start(roomID, userID int64) {
+	k := typingKey{roomID, userID}
+	t.mu.Lock()
+	if tm, ok := t.timers[k]; ok {
+		tm.Reset(t.ttl)
+		t.mu.Unlock()
+		return
+	}
+	var tm *time.Timer
+	tm = time.AfterFunc(t.ttl, func() {
+		t.mu.Lock()
+		if t.timers[k] != tm {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.timers, k)
+		t.mu.Unlock()
+		t.emit(roomID, userID, false)
+	})
+	t.timers[k] = tm
+	t.mu.Unlock()
+	t.emit(roomID, userID, true)
+}
+
+// stop emits typing.stop if the user was typing.
+func (t *typingTracker) stop(roomID, userID int64) {
+	k := typingKey{roomID, userID}
+	t.mu.Lock()
+	tm, ok := t.timers[k]
+	if ok {
+		tm.Stop()
+		delete(t.timers, k)
+	}
+	t.mu.Unlock()
+	if ok {
+		t.emit(roomID, userID, false)
+	}
+}
+
+func (h *Hub) broadcastTyping(roomID, userID int64, typing bool) {
+	typ := "typing.stop"
+	if typing {
+		typ = "typing.start"
+	}
+	h.BroadcastExceptUser(roomID, userID, typ, map[string]any{
+		"room_id": roomID,
+		"user_id": userID,
+	})
+}
+
+// TypingStart relays typing.start to the other room members.
+func (h *Hub) TypingStart(roomID, userID int64) { h.typing.start(roomID, userID) }
+
+// TypingStop relays typing.stop to the other room members, if needed.
+func (h *Hub) TypingStop(roomID, userID int64) { h.typing.stop(roomID, userID) }
+
+// rateLimiter is a per-connection token bucket.
+type rateLimiter struct {
+	tokens float64
+	burst  float64
+	rate   float64 // tokens per second
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{tokens: burst, burst: burst, rate: rate, last: time.Now()}
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
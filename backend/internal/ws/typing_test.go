@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type typingEvent struct {
+	roomID, userID int64
+	typing         bool
+}
+
+func TestTypingTracker_ExpiresWithoutStop(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		evs []typingEvent
+	)
+	tr := newTypingTracker(20*time.Millisecond, func(roomID, userID int64, typing bool) {
+		mu.Lock()
+		evs = append(evs, typingEvent{roomID, userID, typing})
+		mu.Unlock()
+	})
+
+	tr.start(1, 7)
+	tr.start(1, 7) // refresh, no second typing.start
+	time.Sleep(60 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []typingEvent{{1, 7, true}, {1, 7, false}}
+	if len(evs) != len(want) {
+		t.Fatalf("want %v, got %v", want, evs)
+	}
+	for i := range want {
+		if evs[i] != want[i] {
+			t.Fatalf("want %v, got %v", want, evs)
+		}
+	}
+}
+
+func TestTypingTracker_StopOnlyWhenTyping(t *testing.T) {
+	n := 0
+	tr := newTypingTracker(time.Minute, func(int64, int64, bool) { n++ })
+	tr.stop(1, 7)
+	tr.start(1, 7)
+	tr.stop(1, 7)
+	tr.stop(1, 7)
+	if n != 2 {
+		t.Fatalf("want start+stop only, got %d events", n)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(1, 3)
+	l.last = now
+	for i := 0; i < 3; i++ {
+		if !l.allow(now) {
+			t.Fatalf("burst token %d rejected", i)
+		}
+	}
+	if l.allow(now) {
+		t.Fatalf("expected limit after burst")
+	}
+	if !l.allow(now.Add(time.Second)) {
+		t.Fatalf("expected refill after 1s")
+	}
+}
@@ -3,7 +3,14 @@ package main
 import (
 	apihttp "backend/internal/api"
 	authpkg "backend/internal/auth"
+	"backend/internal/blob"
+	"backend/internal/cursor"
+	"backend/internal/jobs"
+	"backend/internal/mail"
+	"backend/internal/msgsvc"
+	"backend/internal/presence"
 	"backend/internal/store"
+	"backend/internal/unfurl"
 	"backend/internal/ws"
 	"context"
 	"database/sql"
@@ -47,6 +54,47 @@ func mustOpenRedis() *redis.Client {
 	return rdb
 }
 
+// mustBlobStorage picks the attachment backend from ATTACHMENT_STORAGE:
+// "s3" for an S3-compatible service, anything else for the local disk.
+func mustBlobStorage() blob.Storage {
+	if os.Getenv("ATTACHMENT_STORAGE") == "s3" {
+		s := &blob.S3Storage{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if s.Endpoint == "" || s.Bucket == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET must be set for ATTACHMENT_STORAGE=s3")
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		return s
+	}
+	dir := os.Getenv("ATTACHMENT_DIR")
+	if dir == "" {
+		dir = "./uploads"
+	}
+	return blob.LocalStorage{Dir: dir}
+}
+
+// assignChannelOwners applies CHANNEL_OWNERS, a comma-separated list of
+// channel=email pairs naming the owners of global channels. Seeded channels
+// have none otherwise, and nobody could assign roles in them.
+func assignChannelOwners(st *store.Store) {
+	for _, pair := range strings.Split(os.Getenv("CHANNEL_OWNERS"), ",") {
+		channel, email, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if err := st.SetChannelOwner(context.Background(), strings.TrimSpace(channel), strings.TrimSpace(email)); err != nil {
+			log.Printf("CHANNEL_OWNERS %s: %v", pair, err)
+		}
+	}
+}
+
 func authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		h := c.GetHeader("Authorization")
@@ -115,13 +163,67 @@ func main() {
 	apiGroup.GET("/me", authMiddleware(), authH.Me)
 
 	st := store.NewFormDB(db)
+	if v, err := strconv.Atoi(os.Getenv("MESSAGE_EDIT_WINDOW_MIN")); err == nil && v > 0 {
+		st.EditWindow = time.Duration(v) * time.Minute
+	}
+	assignChannelOwners(st)
+	hub := ws.NewHub()
+	if os.Getenv("HUB_DISTRIBUTED") == "1" {
+		hub.UseRelay(context.Background(), &ws.RedisRelay{RDB: rdb, Channel: "chat:hub"})
+	}
+
+	tracker := &presence.Tracker{RDB: rdb}
+	hub.UsePresence(tracker)
+	cursorSecret := os.Getenv("CURSOR_SECRET")
+	if cursorSecret == "" {
+		cursorSecret = os.Getenv("JWT_SECRET")
+	}
+
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	retentionDays := 30
+	if v, err := strconv.Atoi(os.Getenv("MESSAGE_TOMBSTONE_RETENTION_DAYS")); err == nil && v > 0 {
+		retentionDays = v
+	}
+	go jobs.RunTombstonePurge(jobsCtx, st, time.Duration(retentionDays)*24*time.Hour, time.Hour)
+	blobs := mustBlobStorage()
+	go jobs.RunEphemeralSweep(jobsCtx, st, blobs, hub.BroadcastMessageDeleted, 5*time.Second)
+	go jobs.RunBookmarkReminders(jobsCtx, st, hub.SendBookmarkReminder, 30*time.Second)
+	go jobs.RunAttachmentCleanup(jobsCtx, st, blobs, 24*time.Hour, 5*time.Minute)
+	var unfurler *unfurl.Worker
+	if os.Getenv("LINK_PREVIEWS") != "0" {
+		unfurler = unfurl.NewWorker(unfurl.NewFetcher(rdb), st, hub.BroadcastEmbedsUpdated, 256)
+		unfurler.Run(jobsCtx, 4)
+	}
+	var spam *msgsvc.SpamGuard
+	if os.Getenv("MESSAGE_SPAM_GUARD") != "0" {
+		spam = msgsvc.NewSpamGuard()
+		if v, err := strconv.Atoi(os.Getenv("MESSAGE_BURST")); err == nil && v > 0 {
+			spam.Burst = float64(v)
+		}
+	}
+	messages := msgsvc.Default(st, hub, unfurler, spam)
+	go jobs.RunScheduledDispatch(jobsCtx, st, messages.Publish, hub.SendScheduledFailed, 10*time.Second)
+	go jobs.RunScheduledPrune(jobsCtx, st, time.Duration(retentionDays)*24*time.Hour, time.Hour)
 
 	// Chat REST
-	chatAPI := &apihttp.Handler{Store: st}
+	chatAPI := &apihttp.Handler{
+		Store:    st,
+		Hub:      hub,
+		Mailer:   mail.LogSender{},
+		Presence: tracker,
+		Cursors:  &cursor.Codec{Key: []byte(cursorSecret)},
+		Blobs:    blobs,
+		Messages: messages,
+	}
+	if v, err := strconv.Atoi(os.Getenv("ATTACHMENT_MAX_MB")); err == nil && v > 0 {
+		chatAPI.MaxUploadBytes = int64(v) << 20
+	}
 	apihttp.Mount(apiGroup, chatAPI, authMiddleware())
 
 	// WebSocket
-	wsh := &ws.Handler{Store: st, Hub: ws.NewHub()}
+	wsh := &ws.Handler{Store: st, Hub: hub, Presence: tracker, Messages: messages}
 	r.GET("/ws", authMiddleware(), wsh.Handle)
 
 	log.Println("backend running on :8080")
@@ -0,0 +1,21 @@
+-- rooms.kind: 'channel' (named, listed) | 'dm' (hidden, membership only)
+ALTER TABLE rooms
+ADD COLUMN IF NOT EXISTS kind   VARCHAR(16) NOT NULL DEFAULT 'channel',
+ADD COLUMN IF NOT EXISTS dm_key TEXT        NULL; -- sorted participant ids, e.g. '3,7,12'
+
+-- one DM room per participant set
+CREATE UNIQUE INDEX IF NOT EXISTS rooms_dm_key_unique ON rooms(dm_key) WHERE dm_key IS NOT NULL;
+
+-- DM rooms all share the placeholder name 'dm', keep name uniqueness for channels only
+DROP INDEX IF EXISTS rooms_name_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS rooms_name_unique ON rooms(name) WHERE workspace_id IS NULL AND kind = 'channel';
+
+CREATE TABLE IF NOT EXISTS room_members (
+    room_id    BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role       VARCHAR(16) NOT NULL DEFAULT 'member',
+    joined_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, user_id)
+);
+-- "my conversations" lookups
+CREATE INDEX IF NOT EXISTS room_members_user ON room_members(user_id);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+    id          BIGSERIAL PRIMARY KEY,
+    name        VARCHAR(64) NOT NULL,
+    created_by  BIGINT      NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id BIGINT      NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id      BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role         VARCHAR(16) NOT NULL DEFAULT 'member', -- 'owner' | 'admin' | 'member'
+    joined_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS workspace_members_user ON workspace_members(user_id);
+
+-- rooms.workspace_id existed before the table, orphans would break tenant checks
+ALTER TABLE rooms
+ADD CONSTRAINT rooms_workspace_fk
+FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS invites (
+    id           BIGSERIAL PRIMARY KEY,
+    code         VARCHAR(32) NOT NULL UNIQUE,
+    workspace_id BIGINT      NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email        CITEXT      NULL, -- NULL: shareable link, else only this address may accept
+    created_by   BIGINT      NULL REFERENCES users(id) ON DELETE SET NULL,
+    expires_at   TIMESTAMPTZ NULL,
+    revoked_at   TIMESTAMPTZ NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS invites_workspace ON invites(workspace_id);
@@ -0,0 +1,19 @@
+-- invites now target either a workspace or a single room
+ALTER TABLE invites
+ALTER COLUMN workspace_id DROP NOT NULL,
+ADD COLUMN IF NOT EXISTS room_id   BIGINT  NULL REFERENCES rooms(id) ON DELETE CASCADE,
+ADD COLUMN IF NOT EXISTS max_uses  INT     NULL, -- NULL: unlimited
+ADD COLUMN IF NOT EXISTS use_count INT     NOT NULL DEFAULT 0;
+
+ALTER TABLE invites
+ADD CONSTRAINT invites_single_target CHECK ((workspace_id IS NULL) <> (room_id IS NULL));
+
+CREATE INDEX IF NOT EXISTS invites_room ON invites(room_id);
+
+-- audit: who joined through which invite
+CREATE TABLE IF NOT EXISTS invite_redemptions (
+    invite_id   BIGINT      NOT NULL REFERENCES invites(id) ON DELETE CASCADE,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redeemed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (invite_id, user_id)
+);
@@ -0,0 +1,9 @@
+-- prior contents of edited messages, newest edit last
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id          BIGSERIAL PRIMARY KEY,
+    message_id  BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    content     TEXT        NOT NULL, -- content before the edit
+    edited_by   BIGINT      NULL REFERENCES users(id) ON DELETE SET NULL,
+    edited_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS message_revisions_message ON message_revisions(message_id, edited_at);
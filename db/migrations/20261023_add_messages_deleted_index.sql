@@ -0,0 +1,2 @@
+-- tombstone purge scans only soft-deleted rows
+CREATE INDEX IF NOT EXISTS messages_deleted_at ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id  BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji       VARCHAR(64) NOT NULL, -- unicode emoji or :shortcode:
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
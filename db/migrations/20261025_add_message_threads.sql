@@ -0,0 +1,6 @@
+-- thread replies point at their root message; roots have parent_id NULL
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS parent_id BIGINT NULL REFERENCES messages(id) ON DELETE CASCADE;
+
+-- keyset pagination inside a thread, and reply counts per root
+CREATE INDEX IF NOT EXISTS messages_parent_created_at ON messages(parent_id, created_at, id) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- inline quote-reply; independent of threads (parent_id)
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS reply_to_id BIGINT NULL REFERENCES messages(id) ON DELETE SET NULL;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id          BIGSERIAL PRIMARY KEY,
+    message_id  BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    room_id     BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- mentioned user
+    kind        VARCHAR(16) NOT NULL, -- 'user' | 'here' | 'channel'
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at     TIMESTAMPTZ NULL,
+    UNIQUE (message_id, user_id)
+);
+-- GET /api/mentions: a user's unread mentions, newest first
+CREATE INDEX IF NOT EXISTS mentions_user_unread ON mentions(user_id, created_at DESC) WHERE read_at IS NULL;
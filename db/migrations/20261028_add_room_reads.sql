@@ -0,0 +1,10 @@
+-- per-user read position in a room; (last_read_at, last_read_message_id) is
+-- compared against messages_room_created_at for unread counts
+CREATE TABLE IF NOT EXISTS room_reads (
+    room_id               BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id               BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id  BIGINT      NOT NULL,
+    last_read_at          TIMESTAMPTZ NOT NULL, -- created_at of last_read_message_id
+    updated_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (room_id, user_id)
+);
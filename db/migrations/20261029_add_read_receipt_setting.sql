@@ -0,0 +1,3 @@
+-- privacy: users may hide their read position from other room members
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS share_read_receipts BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,6 @@
+-- full-text search: 'simple' config, no stemming, works for any language's tokens
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS content_tsv tsvector
+        GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS messages_content_tsv ON messages USING GIN (content_tsv);
@@ -0,0 +1,16 @@
+-- uploaded files; message_id stays NULL until the upload is sent with a message
+CREATE TABLE IF NOT EXISTS attachments (
+    id           BIGSERIAL PRIMARY KEY,
+    room_id      BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    uploader_id  BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id   BIGINT      NULL REFERENCES messages(id) ON DELETE CASCADE,
+    filename     TEXT        NOT NULL,
+    content_type TEXT        NOT NULL,
+    size_bytes   BIGINT      NOT NULL,
+    storage_key  TEXT        NOT NULL UNIQUE,
+    thumb_key    TEXT        NULL,
+    width        INT         NULL,
+    height       INT         NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS attachments_message ON attachments(message_id) WHERE message_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- link previews fetched after the message is sent; NULL until unfurled
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS embeds JSONB NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    message_id  BIGINT      PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    room_id     BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    pinned_by   BIGINT      NULL REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_messages_room ON pinned_messages (room_id, pinned_at DESC);
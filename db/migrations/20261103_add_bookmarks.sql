@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS bookmarks (
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id  BIGINT      NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    remind_at   TIMESTAMPTZ NULL,
+    reminded_at TIMESTAMPTZ NULL, -- set when the reminder was sent
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_created ON bookmarks (user_id, created_at DESC, message_id DESC);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_due ON bookmarks (remind_at) WHERE reminded_at IS NULL;
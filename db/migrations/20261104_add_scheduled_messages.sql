@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id          BIGSERIAL   PRIMARY KEY,
+    room_id     BIGINT      NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content     TEXT        NOT NULL,
+    parent_id   BIGINT      NULL,
+    reply_to_id BIGINT      NULL,
+    send_at     TIMESTAMPTZ NOT NULL,
+    status      VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending | sent | failed
+    error       TEXT        NULL,                       -- why a failed message was not sent
+    message_id  BIGINT      NULL REFERENCES messages(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (send_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_user ON scheduled_messages (user_id, send_at);
@@ -0,0 +1,7 @@
+-- ephemeral messages: deleted for everyone once expires_at passes. They are
+-- hidden from timelines at expiry, never indexed for search results, and
+-- must be skipped by exports.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_expiring ON messages (expires_at)
+    WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
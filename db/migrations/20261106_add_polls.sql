@@ -0,0 +1,25 @@
+-- a poll is a message (content = question) with a polls row
+CREATE TABLE IF NOT EXISTS polls (
+    message_id  BIGINT      PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    multiple    BOOLEAN     NOT NULL DEFAULT FALSE, -- voters may pick several options
+    anonymous   BOOLEAN     NOT NULL DEFAULT FALSE, -- hide who voted for what
+    closes_at   TIMESTAMPTZ NULL
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id          BIGSERIAL    PRIMARY KEY,
+    message_id  BIGINT       NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+    position    INT          NOT NULL,
+    text        VARCHAR(200) NOT NULL,
+    UNIQUE (message_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    option_id   BIGINT      NOT NULL REFERENCES poll_options(id) ON DELETE CASCADE,
+    message_id  BIGINT      NOT NULL REFERENCES polls(message_id) ON DELETE CASCADE,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (option_id, user_id) -- one vote per option per user
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_message_user ON poll_votes (message_id, user_id);
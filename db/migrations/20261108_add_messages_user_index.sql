@@ -0,0 +1,2 @@
+-- "has this user posted in a room I can see" lookups for presence
+CREATE INDEX IF NOT EXISTS messages_user_id_room_id_idx ON messages(user_id, room_id);
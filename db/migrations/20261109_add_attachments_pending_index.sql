@@ -0,0 +1,2 @@
+-- the cleanup job looks for uploads that were never sent
+CREATE INDEX IF NOT EXISTS attachments_created_at_idx ON attachments(created_at) WHERE message_id IS NULL;
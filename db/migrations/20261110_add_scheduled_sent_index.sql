@@ -0,0 +1,2 @@
+-- the prune job looks for scheduled messages sent a while ago
+CREATE INDEX IF NOT EXISTS scheduled_messages_updated_at_idx ON scheduled_messages (updated_at) WHERE status = 'sent';
@@ -7,11 +7,14 @@ import { Link, useNavigate } from "react-router-dom"
 export default function Dashboard() {
     const { token, logout, isAuthed } = useAuth();
     const [me, setMe] = useState(null);
+    const [rooms, setRooms] = useState([]);
     const nav = useNavigate();
 
     useEffect(() => {
         if(!isAuthed) { nav("/login"); return; }
         get("/me", token).then(setMe);
+        // rooms are per-viewer (visibility, unread counts), so the token is required
+        get("/rooms", token).then((res) => setRooms(Array.isArray(res) ? res : []));
     }, [isAuthed, token, nav]);
 
     if (!isAuthed) return null;
@@ -25,6 +28,17 @@ export default function Dashboard() {
                 </div>
             </div>
             <pre className="bg-gray-100 text-black p-3 text-sm">{JSON.stringify(me, null, 2)}</pre>
+            <ul className="divide-y">
+                {rooms.map((r) => (
+                    <li key={r.id} className="flex items-center justify-between py-2">
+                        <span className={r.unread_count > 0 ? "font-semibold" : ""}>#{r.name}</span>
+                        <span className="space-x-2 text-sm">
+                            {r.mention_count > 0 && <span className="text-red-600">@{r.mention_count}</span>}
+                            {r.unread_count > 0 && <span>{r.unread_count >= 1000 ? "999+" : r.unread_count}</span>}
+                        </span>
+                    </li>
+                ))}
+            </ul>
         </div>
     );
 }
\ No newline at end of file